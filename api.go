@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiMaxBodySize bounds the request bodies the API reads.
+const apiMaxBodySize = 1 << 20
+
+// apiRoute is an endpoint of the REST API. The routes both register the
+// handlers and describe them in the OpenAPI document, so the two cannot
+// drift apart.
+type apiRoute struct {
+	Method    string
+	Path      string // relative to /api
+	ID        string // OpenAPI operationId
+	Summary   string
+	Request   any  // zero value of the JSON request body, if it takes one
+	Player    bool // acts for the player named in the X-Player header
+	Responses []apiResponse
+	handle    func(s *webServer, w http.ResponseWriter, r *http.Request)
+}
+
+// apiResponse is a response an endpoint can give. Body is the zero value of
+// its JSON body, or nil when it has none.
+type apiResponse struct {
+	Status      int
+	Description string
+	Body        any
+}
+
+func apiRoutes() []apiRoute {
+	notFound := apiResponse{http.StatusNotFound, "The challenge is not listed in config.json.", apiError{}}
+	notRunning := apiResponse{http.StatusConflict, "The challenge is not running.", apiError{}}
+	badPlayer := apiResponse{http.StatusBadRequest, "The X-Player header is not a valid player name.", apiError{}}
+	return []apiRoute{
+		{
+			Method: "GET", Path: "/challenges", ID: "listChallenges",
+			Summary: "List the challenges in the order of config.json, with the player's progress and running instances.",
+			Player:  true,
+			Responses: []apiResponse{
+				{http.StatusOK, "The challenges.", apiChallengeList{}},
+				badPlayer,
+			},
+			handle: (*webServer).apiListChallenges,
+		},
+		{
+			Method: "POST", Path: "/challenges/{slug}/instances", ID: "startInstance",
+			Summary: "Start a challenge. The request returns once its environment is up, which can take minutes when images are built.",
+			Player:  true,
+			Responses: []apiResponse{
+				{http.StatusCreated, "The challenge is running.", apiInstance{}},
+				badPlayer,
+				notFound,
+				{http.StatusConflict, "The challenge is already starting or running.", apiError{}},
+				{http.StatusInternalServerError, "The challenge could not be started.", apiError{}},
+			},
+			handle: (*webServer).apiStartInstance,
+		},
+		{
+			Method: "DELETE", Path: "/instances/{id}", ID: "stopInstance",
+			Summary: "Stop a challenge and tear its environment down.",
+			Player:  true,
+			Responses: []apiResponse{
+				{http.StatusNoContent, "The challenge was stopped.", nil},
+				badPlayer,
+				{http.StatusNotFound, "The player has no instance with this id.", apiError{}},
+			},
+			handle: (*webServer).apiStopInstance,
+		},
+		{
+			Method: "POST", Path: "/challenges/{slug}/submissions", ID: "submitFlag",
+			Summary: "Submit a flag for a running challenge. Once every flag is found, the challenge is solved and stopped.",
+			Request: apiSubmissionRequest{},
+			Player:  true,
+			Responses: []apiResponse{
+				{http.StatusOK, "The flag was checked; result says whether it was accepted.", apiSubmission{}},
+				{http.StatusBadRequest, "The request body or the X-Player header is not valid.", apiError{}},
+				notFound,
+				notRunning,
+				{http.StatusTooManyRequests, "Too many wrong flags; retry after retry_after seconds.", apiSubmission{}},
+			},
+			handle: (*webServer).apiSubmitFlag,
+		},
+		{
+			Method: "POST", Path: "/challenges/{slug}/hints", ID: "revealHint",
+			Summary: "Reveal the next hint of a running challenge.",
+			Player:  true,
+			Responses: []apiResponse{
+				{http.StatusCreated, "The hint.", apiHint{}},
+				badPlayer,
+				notFound,
+				{http.StatusConflict, "The challenge is not running, has no hints or has no hints left.", apiError{}},
+			},
+			handle: (*webServer).apiRevealHint,
+		},
+		{
+			Method: "GET", Path: "/openapi.json", ID: "getOpenAPI",
+			Summary: "This OpenAPI document.",
+			Responses: []apiResponse{
+				{http.StatusOK, "The OpenAPI document.", nil},
+			},
+			handle: (*webServer).apiOpenAPI,
+		},
+	}
+}
+
+// registerAPI adds the API routes to mux, under /api.
+func (s *webServer) registerAPI(mux *http.ServeMux) {
+	for _, route := range apiRoutes() {
+		mux.HandleFunc(route.Method+" /api"+route.Path, func(w http.ResponseWriter, r *http.Request) {
+			route.handle(s, w, r)
+		})
+	}
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not_found", "no API endpoint %s %s", r.Method, r.URL.Path)
+	})
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Code    string `json:"code" doc:"Stable identifier of the error, e.g. not_running."`
+	Message string `json:"message" doc:"Human-readable description of the error."`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, format string, args ...any) {
+	writeJSON(w, status, apiError{Error: apiErrorBody{Code: code, Message: fmt.Sprintf(format, args...)}})
+}
+
+// apiSlug returns the challenge named in the request path, or answers 404
+// when it is not listed in config.json.
+func (s *webServer) apiSlug(w http.ResponseWriter, r *http.Request) (string, bool) {
+	dir := r.PathValue("slug")
+	if !slices.Contains(s.config.Challenges, dir) {
+		writeError(w, http.StatusNotFound, "not_found", "challenge '%s' is not listed in config.json", dir)
+		return "", false
+	}
+	return dir, true
+}
+
+type apiChallengeList struct {
+	Player     string         `json:"player" doc:"Profile the progress belongs to."`
+	Challenges []apiChallenge `json:"challenges"`
+}
+
+// apiChallenge is a challenge as players see it: everything from its
+// challenge.json but the answers.
+type apiChallenge struct {
+	Number    int          `json:"number" doc:"Position in config.json, from 1."`
+	Slug      string       `json:"slug" doc:"Directory of the challenge."`
+	Name      string       `json:"name"`
+	Type      string       `json:"type,omitempty" doc:"Runtime that runs the challenge: dockerfile or compose."`
+	Ports     []int        `json:"ports,omitempty"`
+	Preface   string       `json:"preface,omitempty"`
+	Flags     int          `json:"flags" doc:"Number of flags that solve the challenge."`
+	Hints     int          `json:"hints" doc:"Number of hints available."`
+	TimeLimit Duration     `json:"time_limit,omitempty"`
+	Points    int          `json:"points,omitempty" doc:"Most points the challenge is worth."`
+	Solved    bool         `json:"solved" doc:"Whether the player has ever solved it."`
+	BestScore *int         `json:"best_score,omitempty"`
+	Found     int          `json:"found,omitempty" doc:"Flags found in an unfinished attempt."`
+	Instance  *apiInstance `json:"instance,omitempty" doc:"The running instance, if any."`
+	Error     string       `json:"error,omitempty" doc:"Why challenge.json could not be loaded."`
+}
+
+// apiInstance is a running challenge.
+type apiInstance struct {
+	ID        string     `json:"id,omitempty" doc:"Session id, also found in the journal. Empty while starting."`
+	Challenge string     `json:"challenge"`
+	State     string     `json:"state" doc:"starting, running, or kept for a solved challenge whose environment is kept running."`
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" doc:"When the instance reaches its TTL and is stopped."`
+	Elapsed   Duration   `json:"elapsed" doc:"Time spent on the challenge, earlier attempts included."`
+	Remaining *Duration  `json:"remaining,omitempty" doc:"Time left, for challenges with a time limit."`
+	Found     int        `json:"found" doc:"Flags found."`
+	Total     int        `json:"total" doc:"Flags that solve the challenge."`
+	Hints     int        `json:"hints" doc:"Hints revealed."`
+	Score     *int       `json:"score,omitempty"`
+}
+
+func (s *webServer) apiListChallenges(w http.ResponseWriter, r *http.Request) {
+	pl, ok := s.requestPlayer(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := apiChallengeList{Player: pl.name, Challenges: []apiChallenge{}}
+	for i, dir := range s.config.Challenges {
+		c := apiChallenge{Number: i + 1, Slug: dir, Name: dir}
+		challengePath := s.config.ChallengePath(dir)
+		if rt := detectRuntime(challengePath); rt != nil {
+			c.Type = rt.Name()
+		}
+		if challenge, err := loadChallenge(challengePath); err != nil {
+			c.Error = err.Error()
+		} else {
+			c.Name, c.Ports, c.Preface = challenge.Name, challenge.Ports, challenge.Preface
+			c.Flags, c.Hints = max(len(challenge.Flags), 1), len(challenge.Hints)
+			c.TimeLimit = challenge.TimeLimit
+			c.Points = newScore(challenge, time.Now()).Max()
+		}
+		if p := pl.store.Challenges[dir]; p != nil {
+			c.Solved, c.BestScore = p.Solved, p.BestScore
+			if p.Attempt != nil {
+				c.Found = len(p.Attempt.Found)
+			}
+		}
+		if ws := pl.sessions[dir]; ws != nil {
+			inst := pl.instanceView(r, dir, ws)
+			c.Instance = &inst
+		}
+		list.Challenges = append(list.Challenges, c)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// instanceView describes a session for the API. The caller holds s.mu.
+func (pl *webPlayer) instanceView(r *http.Request, dir string, ws *webSession) apiInstance {
+	view := apiInstance{Challenge: dir, State: pl.state(dir)}
+	if ws.sess == nil {
+		return view
+	}
+	inst, p, now := ws.sess.inst, ws.play, time.Now()
+	view.ID = inst.SessionID
+	view.Endpoints = endpointsFor(r, inst.Runtime.Endpoints(inst))
+	view.StartedAt = &ws.started
+	if !inst.expires.IsZero() {
+		view.ExpiresAt = &inst.expires
+	}
+	view.Elapsed = Duration(p.elapsed(now).Round(time.Second))
+	if p.limit > 0 {
+		remaining := Duration(p.remaining(now).Round(time.Second))
+		view.Remaining = &remaining
+	}
+	view.Found, view.Total, view.Hints = len(p.found), len(p.flags()), p.score.Hints
+	if p.score.Enabled() {
+		total := p.score.Total()
+		view.Score = &total
+	}
+	return view
+}
+
+// apiRequest returns the challenge and player of an API request.
+func (s *webServer) apiRequest(w http.ResponseWriter, r *http.Request) (*webPlayer, string, bool) {
+	dir, ok := s.apiSlug(w, r)
+	if !ok {
+		return nil, "", false
+	}
+	pl, ok := s.requestPlayer(w, r)
+	return pl, dir, ok
+}
+
+func (s *webServer) apiStartInstance(w http.ResponseWriter, r *http.Request) {
+	pl, dir, ok := s.apiRequest(w, r)
+	if !ok {
+		return
+	}
+	ws, err := s.launch(pl, dir)
+	if err != nil {
+		writeError(w, http.StatusConflict, "already_running", "challenge '%s' is already starting or running", dir)
+		return
+	}
+	if err := s.run(pl, dir, ws); err != nil {
+		writeError(w, http.StatusInternalServerError, "start_failed", "could not start challenge '%s': %v", dir, err)
+		return
+	}
+	s.mu.Lock()
+	view := pl.instanceView(r, dir, ws)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, view)
+}
+
+func (s *webServer) apiStopInstance(w http.ResponseWriter, r *http.Request) {
+	pl, ok := s.requestPlayer(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	var dir string
+	var ws *webSession
+	s.mu.Lock()
+	for d, candidate := range pl.sessions {
+		if candidate.sess != nil && candidate.sess.inst.SessionID == id {
+			dir, ws = d, candidate
+		}
+	}
+	s.mu.Unlock()
+	if ws == nil {
+		writeError(w, http.StatusNotFound, "not_found", "no running instance with id '%s'", id)
+		return
+	}
+	s.end(pl, dir, ws, OutcomeMenu)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type apiSubmissionRequest struct {
+	Flag string `json:"flag" doc:"The flag, as the player would type it."`
+}
+
+// apiSubmission is the result of a flag submission.
+type apiSubmission struct {
+	Result     string   `json:"result" doc:"correct, wrong, duplicate (found before), locked (belongs to a later objective) or throttled."`
+	Flag       *apiFlag `json:"flag,omitempty" doc:"The flag found, for correct submissions."`
+	Feedback   string   `json:"feedback,omitempty" doc:"What the challenge tells about a wrong flag, such as it being close."`
+	RetryAfter int      `json:"retry_after,omitempty" doc:"Seconds to wait before submitting again, when throttled."`
+	Found      int      `json:"found" doc:"Flags found so far."`
+	Total      int      `json:"total" doc:"Flags that solve the challenge."`
+	Solved     bool     `json:"solved" doc:"Whether every flag is found. The instance is then stopped, unless the challenge keeps it running."`
+	Postface   string   `json:"postface,omitempty" doc:"Text shown when the challenge is solved."`
+	Score      *int     `json:"score,omitempty"`
+}
+
+// apiFlag is the objective a correct flag completed.
+type apiFlag struct {
+	ID      string `json:"id,omitempty"`
+	Label   string `json:"label,omitempty"`
+	Points  int    `json:"points" doc:"Points the flag gave."`
+	Message string `json:"message,omitempty"`
+}
+
+func (s *webServer) apiSubmitFlag(w http.ResponseWriter, r *http.Request) {
+	pl, dir, ok := s.apiRequest(w, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, apiMaxBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "could not read the request body: %v", err)
+		return
+	}
+	var req apiSubmissionRequest
+	if err := decodeStrict(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid request body: %v", err)
+		return
+	}
+	input := strings.TrimSpace(req.Flag)
+	if input == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "flag is required")
+		return
+	}
+
+	sub, err := s.submit(pl, dir, input)
+	if err != nil {
+		writeError(w, http.StatusConflict, "not_running", "challenge '%s' is not running", dir)
+		return
+	}
+	resp := apiSubmission{
+		Result:   sub.Result,
+		Feedback: sub.Feedback,
+		Found:    sub.Found,
+		Total:    sub.Total,
+		Solved:   sub.Solved,
+		Postface: sub.Postface,
+		Score:    sub.Score,
+	}
+	if sub.Result == flagCorrect {
+		resp.Flag = &apiFlag{ID: sub.Flag.ID, Label: sub.Flag.Label, Points: sub.Points, Message: sub.Flag.Message}
+	}
+	status := http.StatusOK
+	if sub.Result == flagThrottled {
+		resp.RetryAfter = int(math.Ceil(sub.Wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(resp.RetryAfter))
+		status = http.StatusTooManyRequests
+	}
+	writeJSON(w, status, resp)
+}
+
+// apiHint is a revealed hint.
+type apiHint struct {
+	Number  int    `json:"number" doc:"Position of the hint, from 1."`
+	Total   int    `json:"total" doc:"Hints the challenge has."`
+	Text    string `json:"text"`
+	Penalty int    `json:"penalty" doc:"Points the hint cost."`
+}
+
+func (s *webServer) apiRevealHint(w http.ResponseWriter, r *http.Request) {
+	pl, dir, ok := s.apiRequest(w, r)
+	if !ok {
+		return
+	}
+	h, err := s.hint(pl, dir)
+	switch {
+	case errors.Is(err, errNotRunning):
+		writeError(w, http.StatusConflict, "not_running", "challenge '%s' is not running", dir)
+	case errors.Is(err, errNoHints):
+		writeError(w, http.StatusConflict, "no_hints", "challenge '%s' has no hints", dir)
+	case errors.Is(err, errHintsUsed):
+		writeError(w, http.StatusConflict, "no_hints_left", "every hint of challenge '%s' was already revealed", dir)
+	default:
+		writeJSON(w, http.StatusCreated, apiHint{Number: h.Number, Total: h.Total, Text: h.Text, Penalty: h.Penalty})
+	}
+}
+
+func (s *webServer) apiOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPIDocument())
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// cliEnv carries the options shared by every command.
+type cliEnv struct {
+	ctx   context.Context
+	root  string
+	debug bool
+	// player is the profile named with --player, if any.
+	player string
+
+	// cli is set for commands that need the Docker daemon.
+	cli *client.Client
+}
+
+// command is a start-challenges subcommand.
+type command struct {
+	name    string
+	args    string // argument synopsis shown in the usage line
+	summary string
+	docker  bool // whether the command needs the Docker daemon
+	// define registers the command's own flags and returns the function
+	// that runs it with the remaining arguments.
+	define func(fs *flag.FlagSet) func(env *cliEnv, args []string) int
+}
+
+// commands lists every subcommand, in the order they are shown in help.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "play", summary: "Open the main menu and play the challenges (default)", docker: true, define: definePlay},
+		{name: "list", summary: "List the configured challenges", define: defineList},
+		{name: "build", args: "[slug...]", summary: "Build or pull the images of the given challenges, or of all of them", docker: true, define: defineBuild},
+		{name: "clean", args: "[slug...]", summary: "Remove the containers, networks, volumes and images of the given challenges, or of all of them", docker: true, define: defineClean},
+		{name: "status", summary: "Show the challenge containers that are running", docker: true, define: defineStatus},
+		{name: "logs", args: "<slug>", summary: "Show the container logs of a challenge", docker: true, define: defineLogs},
+		{name: "validate", summary: "Check challenge.json and config.json files against their schemas", define: defineValidate},
+		{name: "lint", summary: "Cross-check challenges against their Dockerfile and compose files", define: defineLint},
+		{name: "hash-flags", args: "[slug...]", summary: "Replace plaintext flags in challenge.json with salted hashes", define: defineHashFlags},
+		{name: "journal", summary: "Show or summarize the journal of player sessions", define: defineJournal},
+		{name: "profiles", args: "[list | reset <player>]", summary: "List player profiles or reset one of them", define: defineProfiles},
+		{name: "new", args: "<slug>", summary: "Create a new challenge from a template", define: defineNew},
+		{name: "serve", summary: "Serve the platform over HTTP with a web UI", docker: true, define: defineServe},
+		{name: "openapi", summary: "Print the OpenAPI document of the REST API served by serve", define: defineOpenAPI},
+		{name: "help", args: "[command]", summary: "Show help for a command", define: defineHelp},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// runCLI parses the command line and runs the chosen command, returning the
+// process exit code.
+//
+// The flags of older versions are still accepted before the command name:
+// --build and --debug apply to play, and --clean runs clean.
+func runCLI(args []string) int {
+	global := flag.NewFlagSet("start-challenges", flag.ContinueOnError)
+	build := global.Bool("build", false, "Force rebuild of all challenge images (same as 'play --build')")
+	clean := global.Bool("clean", false, "Remove all challenge containers, networks, volumes and images (same as 'clean')")
+	debug := global.Bool("debug", false, "Show verbose output including Docker operations")
+	rootFlag := global.String("root", "", "Challenges directory (default $"+rootEnvVar+" or "+defaultRoot+")")
+	player := global.String("player", "", "Player profile to use (asked at startup when playing)")
+	global.Usage = func() { printUsage(global) }
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	name, rest := "play", global.Args()
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
+	} else if *clean {
+		name = "clean"
+	}
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'.\n\n", name)
+		printUsage(global)
+		return 2
+	}
+	if *build && cmd.name == "play" {
+		rest = append([]string{"--build"}, rest...)
+	}
+
+	env := &cliEnv{ctx: context.Background(), debug: *debug, player: *player}
+	fs := flag.NewFlagSet("start-challenges "+cmd.name, flag.ContinueOnError)
+	fs.StringVar(rootFlag, "root", *rootFlag, "Challenges directory (default $"+rootEnvVar+" or "+defaultRoot+")")
+	fs.BoolVar(&env.debug, "debug", env.debug, "Show verbose output including Docker operations")
+	fs.StringVar(&env.player, "player", env.player, "Player profile to use (asked at startup when playing)")
+	run := cmd.define(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: start-challenges %s [flags] %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(rest); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	env.root = resolveRoot(*rootFlag)
+	if env.player != "" {
+		if err := checkPlayerName(env.player); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		activePlayer = env.player
+	}
+
+	if cmd.docker {
+		cli, err := connectDocker(env.ctx)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			return 1
+		}
+		defer cli.Close()
+		env.cli = cli
+	}
+	return run(env, fs.Args())
+}
+
+// printUsage lists the commands and the global flags.
+func printUsage(global *flag.FlagSet) {
+	out := global.Output()
+	fmt.Fprintln(out, "Usage: start-challenges [flags] [command] [arguments]")
+	fmt.Fprintln(out, "\nCommands:")
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	w.Flush()
+	fmt.Fprintln(out, "\nRun 'start-challenges help <command>' for the flags of a command.")
+	fmt.Fprintln(out, "\nFlags:")
+	global.PrintDefaults()
+}
+
+// connectDocker creates a Docker client and checks that the daemon answers.
+func connectDocker(ctx context.Context) (*client.Client, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("could not create Docker client. Is Docker running? Details: %w", err)
+	}
+	if _, err := cli.Ping(ctx); err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("could not connect to Docker daemon. Please make sure Docker is running. Details: %w", err)
+	}
+	return cli, nil
+}
+
+// selectChallenges returns the listed challenges named in slugs, or all of
+// them when slugs is empty.
+func selectChallenges(config Config, slugs []string) ([]string, error) {
+	if len(slugs) == 0 {
+		return config.Challenges, nil
+	}
+	for _, slug := range slugs {
+		if !slices.Contains(config.Challenges, slug) {
+			return nil, fmt.Errorf("challenge '%s' is not listed in config.json", slug)
+		}
+	}
+	return slugs, nil
+}
+
+func definePlay(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	build := fs.Bool("build", false, "Force rebuild of all challenge images")
+	return func(env *cliEnv, args []string) int {
+		// Set up signal handler to catch Ctrl+C
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sigChan)
+
+		// Launch goroutine to handle interrupt signals
+		go func() {
+			for range sigChan {
+				fmt.Println("\n⚠️  Ctrl+C desabilitado. Por favor, digite 'quit' ou 'exit' para encerrar corretamente.")
+			}
+		}()
+
+		fmt.Println("###########################################")
+		fmt.Println("## Bem-vindo à Plataforma de Desafios WSS ##")
+		fmt.Println("###########################################")
+
+		if env.player == "" {
+			activePlayer = pickPlayer()
+		}
+		if err := createProfile(activePlayer); err != nil {
+			log.Printf("Warning: progress will not be saved: %v", err)
+		}
+
+		// Load the main configuration file.
+		config, err := loadConfig(env.root)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			return 1
+		}
+
+		startReaper(env.ctx, env.cli, env.debug)
+		runMenu(env.ctx, env.cli, config, *build, env.debug)
+
+		fmt.Println("\nSessão de desafios encerrada. Até logo!")
+		return 0
+	}
+}
+
+func defineList(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	return func(env *cliEnv, args []string) int {
+		config, err := loadConfig(env.root)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "#\tDIRETÓRIO\tNOME\tTIPO\tPORTAS")
+		for i, dirName := range config.Challenges {
+			challengePath := config.ChallengePath(dirName)
+			kind := "?"
+			if rt := detectRuntime(challengePath); rt != nil {
+				kind = rt.Name()
+			}
+			name, ports := "(challenge.json inválido)", ""
+			if challenge, err := loadChallenge(challengePath); err == nil {
+				name = challenge.Name
+				ports = strings.Trim(fmt.Sprint(challenge.Ports), "[]")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, dirName, name, kind, ports)
+		}
+		w.Flush()
+		return 0
+	}
+}
+
+func defineBuild(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	return func(env *cliEnv, args []string) int {
+		config, err := loadConfig(env.root)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			return 1
+		}
+		selected, err := selectChallenges(config, args)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			return 2
+		}
+		status := 0
+		for _, dirName := range selected {
+			inst, err := newInstance(env.cli, config, dirName)
+			if err != nil {
+				log.Printf("Error: %v", err)
+				status = 1
+				continue
+			}
+			inst.ForceBuild, inst.Debug = true, env.debug
+			fmt.Printf("Construindo '%s'...\n", dirName)
+			if err := inst.Runtime.Prepare(env.ctx, inst); err != nil {
+				log.Printf("Error: Failed to build challenge %s. Details: %v", dirName, err)
+				status = 1
+			}
+		}
+		return status
+	}
+}
+
+func defineClean(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	return func(env *cliEnv, args []string) int {
+		// Resources are found by label, which needs no config.json; it
+		// only adds the unlabelled resources of the challenges it lists.
+		config, err := loadConfig(env.root)
+		if err != nil {
+			log.Printf("Warning: %v; removing labelled resources only", err)
+		}
+		fmt.Println("Limpando containers, redes, volumes e imagens dos desafios...")
+		removed, err := cleanAll(env.ctx, env.cli, config, args, env.debug)
+		fmt.Printf("Removidos: %d container(s), %d rede(s), %d volume(s) e %d imagem(ns).\n", removed.Containers, removed.Networks, removed.Volumes, removed.Images)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			return 1
+		}
+		fmt.Println("Todos os recursos dos desafios foram removidos.")
+		return 0
+	}
+}
+
+func defineStatus(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	all := fs.Bool("all", false, "Include stopped containers")
+	return func(env *cliEnv, args []string) int {
+		filter := filters.NewArgs(filters.Arg("label", labelChallenge))
+		containers, err := env.cli.ContainerList(env.ctx, container.ListOptions{All: *all, Filters: filter})
+		if err != nil {
+			log.Printf("Error: could not list containers: %v", err)
+			return 1
+		}
+		if len(containers) == 0 {
+			fmt.Println("Nenhum desafio em execução.")
+			return 0
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "DESAFIO\tSERVIÇO\tCONTAINER\tESTADO\tPORTAS\tEXPIRA EM")
+		for _, c := range containers {
+			var ports []string
+			for _, p := range c.Ports {
+				if p.PublicPort != 0 {
+					ports = append(ports, fmt.Sprintf("%d->%d", p.PublicPort, p.PrivatePort))
+				}
+			}
+			slices.Sort(ports)
+			ports = slices.Compact(ports)
+			name := strings.TrimPrefix(strings.Join(c.Names, ","), "/")
+			expires := "-"
+			if t, err := time.Parse(time.RFC3339, c.Labels[labelExpires]); err == nil {
+				expires = max(time.Until(t), 0).Round(time.Minute).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Labels[labelChallenge], c.Labels[labelService], name, c.Status, strings.Join(ports, ", "), expires)
+		}
+		w.Flush()
+		return 0
+	}
+}
+
+func defineLogs(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	follow := fs.Bool("follow", false, "Keep streaming new log lines")
+	tail := fs.String("tail", "100", "Number of lines to show from the end of the logs, or \"all\"")
+	return func(env *cliEnv, args []string) int {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: start-challenges logs [flags] <slug>")
+			return 2
+		}
+		filter := filters.NewArgs(filters.Arg("label", labelChallenge+"="+args[0]))
+		containers, err := env.cli.ContainerList(env.ctx, container.ListOptions{All: true, Filters: filter})
+		if err != nil {
+			log.Printf("Error: could not list containers: %v", err)
+			return 1
+		}
+		if len(containers) == 0 {
+			fmt.Printf("Nenhum container encontrado para o desafio '%s'.\n", args[0])
+			return 1
+		}
+
+		opts := container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: *follow, Tail: *tail}
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for _, c := range containers {
+			prefix := ""
+			if len(containers) > 1 {
+				prefix = strings.TrimPrefix(c.Names[0], "/") + " | "
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rc, err := env.cli.ContainerLogs(env.ctx, c.ID, opts)
+				if err != nil {
+					log.Printf("Warning: could not read logs of %s: %v", c.Names[0], err)
+					return
+				}
+				defer rc.Close()
+				out := &prefixWriter{w: os.Stdout, prefix: prefix, mu: &mu}
+				stdcopy.StdCopy(out, out, rc)
+				out.Flush()
+			}()
+		}
+		wg.Wait()
+		return 0
+	}
+}
+
+// prefixWriter writes complete lines to w, each preceded by prefix. Writers
+// sharing a mutex never interleave their lines.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		p.mu.Lock()
+		_, err := fmt.Fprintf(p.w, "%s%s", p.prefix, p.buf[:i+1])
+		p.mu.Unlock()
+		p.buf = p.buf[i+1:]
+		if err != nil {
+			return len(b), err
+		}
+	}
+}
+
+// Flush writes out a trailing partial line, if any.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.Write([]byte("\n"))
+	}
+}
+
+func defineValidate(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	return func(env *cliEnv, args []string) int {
+		return runValidate(env.root)
+	}
+}
+
+func defineLint(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	return func(env *cliEnv, args []string) int {
+		return runLint(env.root)
+	}
+}
+
+func defineHashFlags(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	dryRun := fs.Bool("dry-run", false, "Only report what would be changed")
+	return func(env *cliEnv, args []string) int {
+		return runHashFlags(env.root, args, *dryRun)
+	}
+}
+
+func defineJournal(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	var filter journalFilter
+	fs.StringVar(&filter.Challenge, "challenge", "", "Only show events of this challenge")
+	fs.StringVar(&filter.Session, "session", "", "Only show events of this session (a prefix is enough)")
+	types := fs.String("type", "", "Only show events of these comma-separated types")
+	since := fs.String("since", "", "Only show events since a duration ago (24h) or a date (2006-01-02)")
+	summary := fs.Bool("summary", false, "Summarize the events per challenge")
+	asJSON := fs.Bool("json", false, "Print the matching events as JSON lines")
+	return func(env *cliEnv, args []string) int {
+		if *types != "" {
+			filter.Types = strings.Split(*types, ",")
+		}
+		if *since != "" {
+			t, err := parseSince(*since, time.Now())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+			filter.Since = t
+		}
+		return runJournal(filter, *summary, *asJSON)
+	}
+}
+
+func defineProfiles(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	yes := fs.Bool("yes", false, "Reset without asking for confirmation")
+	return func(env *cliEnv, args []string) int {
+		return runProfiles(args, *yes)
+	}
+}
+
+func defineNew(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	kind := fs.String("type", "dockerfile", "Challenge type: "+strings.Join(challengeTypes, ", "))
+	name := fs.String("name", "", "Display name (default derived from the slug)")
+	return func(env *cliEnv, args []string) int {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: start-challenges new [--type dockerfile|compose|static] [--name NAME] <slug>")
+			return 2
+		}
+		return runNew(env.root, *kind, args[0], *name)
+	}
+}
+
+func defineServe(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	addr := fs.String("addr", "127.0.0.1:8000", "Address to serve the web UI on")
+	token := fs.String("token", "", "Token every request must carry (default $WSS_CTF_TOKEN, or a random one)")
+	build := fs.Bool("build", false, "Force rebuild of challenge images")
+	return func(env *cliEnv, args []string) int {
+		config, err := loadConfig(env.root)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			return 1
+		}
+		if err := createProfile(activePlayer); err != nil {
+			log.Printf("Warning: progress will not be saved: %v", err)
+		}
+		if *token == "" {
+			*token = os.Getenv("WSS_CTF_TOKEN")
+		}
+		return runServe(env.ctx, env.cli, config, *addr, *token, *build, env.debug)
+	}
+}
+
+func defineOpenAPI(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	return func(env *cliEnv, args []string) int {
+		return runOpenAPI()
+	}
+}
+
+func defineHelp(fs *flag.FlagSet) func(env *cliEnv, args []string) int {
+	return func(env *cliEnv, args []string) int {
+		if len(args) == 0 {
+			return runCLI([]string{"--help"})
+		}
+		if findCommand(args[0]) == nil {
+			fmt.Fprintf(os.Stderr, "Unknown command '%s'.\n", args[0])
+			return 2
+		}
+		return runCLI([]string{args[0], "--help"})
+	}
+}
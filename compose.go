@@ -0,0 +1,450 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"gopkg.in/yaml.v3"
+)
+
+// composeFile is the subset of the Compose specification the platform
+// understands. Keys it does not know about are ignored and listed in
+// unsupported, except those in composeRestrictedKeys, which are refused.
+type composeFile struct {
+	Name     string                     `yaml:"name"`
+	Services map[string]*composeService `yaml:"services"`
+	Networks map[string]*composeNetwork `yaml:"networks"`
+	Volumes  map[string]*composeVolume  `yaml:"volumes"`
+
+	// unsupported lists the ignored keys as dotted paths, such as
+	// services.web.restart.
+	unsupported []string
+}
+
+// composeRestrictedKeys are the service keys that harden or isolate a
+// container. Ignoring them would run it with more privileges than its
+// author asked for, so a compose file that sets them is refused.
+var composeRestrictedKeys = []string{
+	"cap_add", "cap_drop", "devices", "env_file", "ipc", "network_mode", "pid",
+	"privileged", "read_only", "security_opt", "tmpfs", "user", "userns_mode",
+}
+
+type composeService struct {
+	Image         string              `yaml:"image"`
+	Build         *composeBuild       `yaml:"build"`
+	Hostname      string              `yaml:"hostname"`
+	ContainerName string              `yaml:"container_name"`
+	Command       shellCommand        `yaml:"command"`
+	Entrypoint    shellCommand        `yaml:"entrypoint"`
+	Environment   composeEnvironment  `yaml:"environment"`
+	Networks      composeNames        `yaml:"networks"`
+	Volumes       []string            `yaml:"volumes"`
+	Ports         []string            `yaml:"ports"`
+	DependsOn     composeDependencies `yaml:"depends_on"`
+	Healthcheck   *composeHealthcheck `yaml:"healthcheck"`
+}
+
+type composeNetwork struct {
+	Driver string `yaml:"driver"`
+}
+
+type composeVolume struct {
+	Driver string `yaml:"driver"`
+}
+
+// composeBuild accepts both the short (`build: ./dir`) and the long form.
+type composeBuild struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+}
+
+func (b *composeBuild) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		b.Context = node.Value
+		return nil
+	}
+	type plain composeBuild
+	return node.Decode((*plain)(b))
+}
+
+type composeHealthcheck struct {
+	Test        shellCommand `yaml:"test"`
+	Interval    string       `yaml:"interval"`
+	Timeout     string       `yaml:"timeout"`
+	StartPeriod string       `yaml:"start_period"`
+	Retries     int          `yaml:"retries"`
+	Disable     bool         `yaml:"disable"`
+}
+
+// shellCommand is a command given either as a list or as a single string
+// split with shell quoting rules. shell is set for the string form.
+type shellCommand struct {
+	Args  []string
+	Raw   string
+	shell bool
+}
+
+func (c *shellCommand) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		args, err := splitShellWords(node.Value)
+		if err != nil {
+			return err
+		}
+		c.Args, c.Raw, c.shell = args, node.Value, true
+		return nil
+	}
+	return node.Decode(&c.Args)
+}
+
+// composeEnvironment accepts both the list (`- KEY=value`) and map forms.
+type composeEnvironment []string
+
+func (e *composeEnvironment) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		return node.Decode((*[]string)(e))
+	}
+	var m map[string]*string
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(m) {
+		if m[key] == nil {
+			*e = append(*e, key)
+		} else {
+			*e = append(*e, key+"="+*m[key])
+		}
+	}
+	return nil
+}
+
+// composeNames accepts a list of names or a map keyed by name, as used by
+// a service's networks.
+type composeNames []string
+
+func (n *composeNames) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		return node.Decode((*[]string)(n))
+	}
+	var m map[string]yaml.Node
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+	*n = sortedKeys(m)
+	return nil
+}
+
+// Conditions a service can wait for in depends_on.
+const (
+	conditionStarted   = "service_started"
+	conditionHealthy   = "service_healthy"
+	conditionCompleted = "service_completed_successfully"
+)
+
+// composeDependencies maps each dependency to the condition to wait for.
+type composeDependencies map[string]string
+
+func (d *composeDependencies) UnmarshalYAML(node *yaml.Node) error {
+	*d = make(composeDependencies)
+	if node.Kind == yaml.SequenceNode {
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return err
+		}
+		for _, name := range names {
+			(*d)[name] = conditionStarted
+		}
+		return nil
+	}
+	var m map[string]struct {
+		Condition string `yaml:"condition"`
+	}
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+	for name, dep := range m {
+		if dep.Condition == "" {
+			dep.Condition = conditionStarted
+		}
+		(*d)[name] = dep.Condition
+	}
+	return nil
+}
+
+// loadComposeFile parses the docker-compose.yml in a challenge directory.
+func loadComposeFile(challengePath string) (*composeFile, error) {
+	composePath := filepath.Join(challengePath, "docker-compose.yml")
+	data, err := os.ReadFile(composePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", composePath, err)
+	}
+	var cf composeFile
+	if err := yaml.Unmarshal(data, &cf); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", composePath, err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", composePath, err)
+	}
+	if len(root.Content) > 0 {
+		cf.unsupported = unknownKeys(root.Content[0], reflect.TypeFor[composeFile](), "")
+	}
+	for _, key := range cf.unsupported {
+		parts := strings.Split(key, ".")
+		if len(parts) == 3 && parts[0] == "services" && slices.Contains(composeRestrictedKeys, parts[2]) {
+			return nil, fmt.Errorf("%s: service '%s' sets %s, which the platform does not support", composePath, parts[1], parts[2])
+		}
+	}
+	if len(cf.Services) == 0 {
+		return nil, fmt.Errorf("%s defines no services", composePath)
+	}
+	for name, svc := range cf.Services {
+		if svc == nil {
+			return nil, fmt.Errorf("%s: service '%s' is empty", composePath, name)
+		}
+		if svc.Image == "" && svc.Build == nil {
+			return nil, fmt.Errorf("%s: service '%s' has neither image nor build", composePath, name)
+		}
+		for dep := range svc.DependsOn {
+			if _, ok := cf.Services[dep]; !ok {
+				return nil, fmt.Errorf("%s: service '%s' depends on unknown service '%s'", composePath, name, dep)
+			}
+		}
+	}
+	return &cf, nil
+}
+
+// unknownKeys returns the keys of a YAML mapping, and of the mappings
+// nested in it, that have no field in t, as dotted paths under prefix.
+// Extension keys (x-*) and the obsolete top-level version are allowed.
+func unknownKeys(node *yaml.Node, t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var unknown []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		path := prefix + key
+		switch t.Kind() {
+		case reflect.Map:
+			unknown = append(unknown, unknownKeys(value, t.Elem(), path+".")...)
+		case reflect.Struct:
+			field, ok := yamlField(t, key)
+			if !ok {
+				if !strings.HasPrefix(key, "x-") && path != "version" {
+					unknown = append(unknown, path)
+				}
+				continue
+			}
+			unknown = append(unknown, unknownKeys(value, field.Type, path+".")...)
+		}
+	}
+	return unknown
+}
+
+// yamlField returns the field of struct t decoded from key.
+func yamlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if name, _, _ := strings.Cut(f.Tag.Get("yaml"), ","); f.IsExported() && name == key {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// startOrder returns the service names sorted so that every service comes
+// after the services it depends on. Services are grouped by dependency
+// depth, so that everything a service may wait on is already started by the
+// time its turn comes (a cluster member may only become healthy once its
+// peers are up).
+func (cf *composeFile) startOrder() ([]string, error) {
+	depth := make(map[string]int)
+	visiting := make(map[string]bool)
+	var visit func(name string) (int, error)
+	visit = func(name string) (int, error) {
+		if d, ok := depth[name]; ok {
+			return d, nil
+		}
+		if visiting[name] {
+			return 0, fmt.Errorf("dependency cycle involving service '%s'", name)
+		}
+		visiting[name] = true
+		d := 0
+		for dep := range cf.Services[name].DependsOn {
+			depDepth, err := visit(dep)
+			if err != nil {
+				return 0, err
+			}
+			d = max(d, depDepth+1)
+		}
+		depth[name] = d
+		return d, nil
+	}
+
+	order := sortedKeys(cf.Services)
+	for _, name := range order {
+		if _, err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return depth[order[i]] < depth[order[j]]
+	})
+	return order, nil
+}
+
+// composeProjectName derives the project name the same way docker compose
+// does: the top-level name if given, otherwise the directory name, lowercased
+// and stripped of characters Docker does not accept.
+func composeProjectName(cf *composeFile, dirName string) string {
+	name := cf.Name
+	if name == "" {
+		name = dirName
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// healthConfig converts a service healthcheck to its Docker API form.
+func (h *composeHealthcheck) healthConfig() (*container.HealthConfig, error) {
+	if h == nil {
+		return nil, nil
+	}
+	if h.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}, nil
+	}
+	hc := &container.HealthConfig{Retries: h.Retries}
+	if h.Test.shell {
+		hc.Test = []string{"CMD-SHELL", h.Test.Raw}
+	} else {
+		hc.Test = h.Test.Args
+	}
+	for _, d := range []struct {
+		value string
+		dst   *time.Duration
+	}{
+		{h.Interval, &hc.Interval},
+		{h.Timeout, &hc.Timeout},
+		{h.StartPeriod, &hc.StartPeriod},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid healthcheck duration '%s': %w", d.value, err)
+		}
+		*d.dst = parsed
+	}
+	return hc, nil
+}
+
+// composeMount is a parsed entry of a service's volumes list.
+type composeMount struct {
+	Source   string // host path for binds, volume name otherwise; empty for anonymous volumes
+	Target   string
+	Bind     bool
+	ReadOnly bool
+}
+
+// parseComposeMount parses the short volume syntax (SOURCE:TARGET[:MODE]).
+// Relative bind sources are resolved against the challenge directory.
+func parseComposeMount(spec, challengePath string) (composeMount, error) {
+	parts := strings.Split(spec, ":")
+	var m composeMount
+	switch len(parts) {
+	case 1:
+		m.Target = parts[0]
+		return m, nil
+	case 2:
+		m.Source, m.Target = parts[0], parts[1]
+	case 3:
+		m.Source, m.Target = parts[0], parts[1]
+		m.ReadOnly = parts[2] == "ro" || strings.HasPrefix(parts[2], "ro,") || strings.Contains(parts[2], ",ro")
+	default:
+		return m, fmt.Errorf("invalid volume '%s'", spec)
+	}
+	if strings.HasPrefix(m.Source, ".") || strings.HasPrefix(m.Source, "/") || strings.HasPrefix(m.Source, "~") {
+		m.Bind = true
+		if strings.HasPrefix(m.Source, "~") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return m, err
+			}
+			m.Source = filepath.Join(home, m.Source[1:])
+		} else if !filepath.IsAbs(m.Source) {
+			m.Source = filepath.Join(challengePath, m.Source)
+		}
+	}
+	return m, nil
+}
+
+// splitShellWords splits a command line into words, honoring single and
+// double quotes and backslash escapes the way a POSIX shell would.
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in '%s'", s)
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// defaultRoot is where challenges live in the player VM.
+	defaultRoot = "/wss-ctf/challenges"
+	// rootEnvVar overrides defaultRoot when --root is not given.
+	rootEnvVar = "WSS_CTF_ROOT"
+)
+
+// resolveRoot picks the challenges directory: the --root flag first, then
+// $WSS_CTF_ROOT, then defaultRoot.
+func resolveRoot(flagValue string) string {
+	root := flagValue
+	if root == "" {
+		root = os.Getenv(rootEnvVar)
+	}
+	if root == "" {
+		root = defaultRoot
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return root
+}
+
+// stateDir returns the directory where the platform keeps what it records
+// about players, one directory per profile: $XDG_STATE_HOME/wss-ctf, or
+// ~/.local/state/wss-ctf when XDG_STATE_HOME is not set.
+func stateDir() (string, error) {
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not find the state directory: %w", err)
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(base, "wss-ctf"), nil
+}
+
+// loadConfig reads config.json from the challenges root. Every path to a
+// challenge is built from the returned Config.
+func loadConfig(root string) (Config, error) {
+	config := Config{Root: root}
+	configPath := filepath.Join(root, "config.json")
+	configFile, err := os.ReadFile(configPath)
+	if err != nil {
+		return config, fmt.Errorf("could not read %s: %w", configPath, err)
+	}
+	if err := decodeStrict(configFile, &config); err != nil {
+		return config, fmt.Errorf("could not parse %s: %w", configPath, err)
+	}
+	return config, nil
+}
+
+// ChallengePath returns the directory of a challenge listed in config.json.
+func (c Config) ChallengePath(dirName string) string {
+	return filepath.Join(c.Root, dirName)
+}
+
+// loadChallenge reads and parses the challenge.json in a challenge directory.
+func loadChallenge(challengePath string) (Challenge, error) {
+	var challenge Challenge
+	challengeFile, err := os.ReadFile(filepath.Join(challengePath, "challenge.json"))
+	if err != nil {
+		return challenge, fmt.Errorf("could not read challenge.json in %s: %w", challengePath, err)
+	}
+	if err := decodeStrict(challengeFile, &challenge); err != nil {
+		return challenge, fmt.Errorf("could not parse challenge.json in %s: %w", challengePath, err)
+	}
+	return challenge, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// DynamicFlag generates a different flag for every session, so that
+// players cannot simply share their answers. The flag is rendered from
+// Template and handed to the challenge through any of Env, File and
+// BuildArg.
+type DynamicFlag struct {
+	// Template is the flag with placeholders, e.g. "WSS{leak_{{random:8}}}".
+	Template string `json:"template"`
+	// Env is the environment variable set to the flag in the containers.
+	Env string `json:"env,omitempty"`
+	// File is the path inside the containers where the flag is mounted.
+	File string `json:"file,omitempty"`
+	// BuildArg is the build argument set to the flag when building images.
+	// Images are then built for every session, under a tag of their own.
+	BuildArg string `json:"build_arg,omitempty"`
+	// Services limits injection to these compose services. All services
+	// get the flag when it is empty.
+	Services []string `json:"services,omitempty"`
+}
+
+// flagPlaceholder matches the placeholders of a dynamic flag template.
+var flagPlaceholder = regexp.MustCompile(`\{\{\s*([a-z]+)(?::(\d+))?\s*\}\}`)
+
+// flagAlphabet is what random placeholders are drawn from.
+const flagAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// Generate renders the template into a fresh flag.
+//
+// Supported placeholders:
+//
+//	{{random:N}}  N random lowercase letters and digits (1-64)
+func (d DynamicFlag) Generate() (string, error) {
+	var genErr error
+	flag := flagPlaceholder.ReplaceAllStringFunc(d.Template, func(p string) string {
+		m := flagPlaceholder.FindStringSubmatch(p)
+		if m[1] != "random" {
+			genErr = fmt.Errorf("unknown placeholder '%s' in flag template", p)
+			return ""
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil || n < 1 || n > 64 {
+			genErr = fmt.Errorf("placeholder '%s' needs a length between 1 and 64", p)
+			return ""
+		}
+		s, err := randomString(n)
+		if err != nil {
+			genErr = err
+		}
+		return s
+	})
+	return flag, genErr
+}
+
+// randomString returns n characters drawn uniformly from flagAlphabet.
+func randomString(n int) (string, error) {
+	buf := make([]byte, 0, n)
+	b := make([]byte, 1)
+	for len(buf) < n {
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("could not generate random flag: %w", err)
+		}
+		// Reject bytes past the last full multiple of the alphabet to
+		// avoid modulo bias.
+		if int(b[0]) >= 256-256%len(flagAlphabet) {
+			continue
+		}
+		buf = append(buf, flagAlphabet[int(b[0])%len(flagAlphabet)])
+	}
+	return string(buf), nil
+}
+
+// appliesTo reports whether the flag is injected into a compose service.
+func (d DynamicFlag) appliesTo(service string) bool {
+	if len(d.Services) == 0 {
+		return true
+	}
+	return slices.Contains(d.Services, service)
+}
+
+// generateFlag gives the instance its own flag when the challenge asks for
+// a dynamic one. The generated value replaces the flag from challenge.json
+// in the instance's copy of the challenge, so submissions are checked
+// against it. Its hash is journaled with the session id, so a flag shared
+// by another player can be traced back to the session it came from.
+func (inst *ChallengeInstance) generateFlag() error {
+	df := inst.Challenge.DynamicFlag
+	if df == nil {
+		return nil
+	}
+	flag, err := df.Generate()
+	if err != nil {
+		return err
+	}
+	challenge := inst.Challenge
+	challenge.Flag = FlagSpec{Value: flag}
+	challenge.Flags = nil
+	inst.Challenge = challenge
+	inst.flag = flag
+	inst.event(Event{Type: eventFlagGenerated, Input: inputHash(flag)})
+
+	if df.File != "" {
+		dir, err := os.MkdirTemp("", "wss-ctf-flag-")
+		if err != nil {
+			return fmt.Errorf("could not create flag file: %w", err)
+		}
+		inst.flagFile = filepath.Join(dir, "flag")
+		// The file is readable by anyone, so that processes running as
+		// another user in the container can read it once mounted. The
+		// directory MkdirTemp creates is private to the owner, which keeps
+		// other users of a shared host from reaching it.
+		if err := os.WriteFile(inst.flagFile, []byte(flag+"\n"), 0o644); err != nil {
+			return fmt.Errorf("could not write flag file: %w", err)
+		}
+	}
+	return nil
+}
+
+// removeFlagFile deletes the host file holding the instance's flag, if any.
+func (inst *ChallengeInstance) removeFlagFile() {
+	if inst.flagFile == "" {
+		return
+	}
+	if err := os.RemoveAll(filepath.Dir(inst.flagFile)); err != nil {
+		log.Printf("Warning: could not remove flag file %s: %v", inst.flagFile, err)
+	}
+	inst.flagFile = ""
+}
+
+// flagEnv returns the environment entries that hand the dynamic flag to a
+// container of the given compose service ("" for Dockerfile challenges).
+func (inst *ChallengeInstance) flagEnv(service string) []string {
+	df := inst.Challenge.DynamicFlag
+	if inst.flag == "" || df.Env == "" || (service != "" && !df.appliesTo(service)) {
+		return nil
+	}
+	return []string{df.Env + "=" + inst.flag}
+}
+
+// flagMounts returns the read-only bind mount of the flag file for a
+// container of the given compose service ("" for Dockerfile challenges).
+func (inst *ChallengeInstance) flagMounts(service string) []mount.Mount {
+	df := inst.Challenge.DynamicFlag
+	if inst.flagFile == "" || (service != "" && !df.appliesTo(service)) {
+		return nil
+	}
+	return []mount.Mount{{Type: mount.TypeBind, Source: inst.flagFile, Target: df.File, ReadOnly: true}}
+}
+
+// flagBuildArgs returns the build arguments that bake the dynamic flag into
+// the image of the given compose service ("" for Dockerfile challenges).
+func (inst *ChallengeInstance) flagBuildArgs(service string) map[string]*string {
+	df := inst.Challenge.DynamicFlag
+	if inst.flag == "" || df.BuildArg == "" || (service != "" && !df.appliesTo(service)) {
+		return nil
+	}
+	return map[string]*string{df.BuildArg: &inst.flag}
+}
+
+// removeSessionImages removes the images built for this instance alone,
+// which have its flag baked in. Images shared by every instance do not
+// carry the session label and are left alone.
+func (inst *ChallengeInstance) removeSessionImages(ctx context.Context) error {
+	if inst.SessionID == "" {
+		return nil
+	}
+	_, err := removeLabeledImages(ctx, inst.cli, labelFilter(map[string]string{labelChallenge: inst.DirName, labelSession: inst.SessionID}))
+	return err
+}
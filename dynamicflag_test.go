@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateFlagFile(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("TMPDIR", t.TempDir())
+	inst := &ChallengeInstance{SessionID: "test", DirName: "test"}
+	inst.Challenge.DynamicFlag = &DynamicFlag{Template: "WSS{test_{{random:8}}}", File: "/flag"}
+	if err := inst.generateFlag(); err != nil {
+		t.Fatal(err)
+	}
+	defer inst.removeFlagFile()
+
+	data, err := os.ReadFile(inst.flagFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != inst.flag+"\n" {
+		t.Errorf("flag file holds %q, want %q", data, inst.flag+"\n")
+	}
+	// Container users other than the owner read the file through its bind
+	// mount; the host reaches it only through the owner's directory.
+	for path, want := range map[string]os.FileMode{inst.flagFile: 0o644, filepath.Dir(inst.flagFile): 0o700} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s: mode %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// flagExecTimeout bounds how long an exec validator may take.
+const flagExecTimeout = 10 * time.Second
+
+// matchFlag reports whether input is accepted by f for this instance. Exec
+// validators are run with the submission as their last argument and accept
+// it by exiting with status 0; a validator that cannot be run rejects it.
+func (inst *ChallengeInstance) matchFlag(ctx context.Context, f FlagSpec, input string) bool {
+	if f.mode() != matchExec {
+		return f.Matches(input)
+	}
+	ctx, cancel := context.WithTimeout(ctx, flagExecTimeout)
+	defer cancel()
+
+	args, err := splitShellWords(f.Exec)
+	if err != nil {
+		log.Printf("Warning: invalid flag validator '%s': %v", f.Exec, err)
+		return false
+	}
+	args = append(args, input)
+	var ok bool
+	if f.In == execInContainer {
+		ok, err = inst.execInContainer(ctx, f.Service, args)
+	} else {
+		ok, err = inst.execInDirectory(ctx, args)
+	}
+	if err != nil {
+		log.Printf("Warning: flag validator '%s' failed: %v", f.Exec, err)
+		return false
+	}
+	return ok
+}
+
+// execInDirectory runs a validator on the host from the challenge
+// directory. Commands found in that directory are run from there; others
+// are looked up in PATH.
+func (inst *ChallengeInstance) execInDirectory(ctx context.Context, args []string) (bool, error) {
+	name := args[0]
+	if !filepath.IsAbs(name) && fileIn(inst.Path, name) {
+		name = filepath.Join(inst.Path, name)
+	}
+	cmd := exec.CommandContext(ctx, name, args[1:]...)
+	cmd.Dir = inst.Path
+	if inst.verbose() {
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	}
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// execInContainer runs a validator inside the running container of the
+// challenge, or of one of its compose services.
+func (inst *ChallengeInstance) execInContainer(ctx context.Context, service string, args []string) (bool, error) {
+	labels := inst.resourceLabels(nil)
+	if inst.SessionID != "" {
+		labels[labelSession] = inst.SessionID
+	}
+	if service != "" {
+		labels[labelService] = service
+	}
+	containers, err := inst.cli.ContainerList(ctx, container.ListOptions{Filters: labelFilter(labels)})
+	if err != nil {
+		return false, fmt.Errorf("could not list containers: %w", err)
+	}
+	if len(containers) == 0 {
+		return false, fmt.Errorf("no running container found")
+	}
+	if len(containers) > 1 && service == "" {
+		return false, fmt.Errorf("challenge has several containers; set the flag's service")
+	}
+
+	exe, err := inst.cli.ContainerExecCreate(ctx, containers[0].ID, container.ExecOptions{
+		Cmd:          args,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return false, fmt.Errorf("could not create exec: %w", err)
+	}
+	resp, err := inst.cli.ContainerExecAttach(ctx, exe.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return false, fmt.Errorf("could not attach to exec: %w", err)
+	}
+	defer resp.Close()
+
+	// Reading the output to the end waits for the validator to finish.
+	var out io.Writer = io.Discard
+	if inst.verbose() {
+		out = os.Stdout
+	}
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(out, io.MultiWriter(out, &stderr), resp.Reader); err != nil {
+		return false, fmt.Errorf("could not read exec output: %w", err)
+	}
+	info, err := inst.cli.ContainerExecInspect(ctx, exe.ID)
+	if err != nil {
+		return false, fmt.Errorf("could not inspect exec: %w", err)
+	}
+	if info.ExitCode == 126 || info.ExitCode == 127 {
+		return false, fmt.Errorf("exit code %d: %s", info.ExitCode, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return info.ExitCode == 0, nil
+}
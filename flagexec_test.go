@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchFlagExecInDirectory(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1\" = 'WSS{ok}' ]\n"
+	if err := os.WriteFile(filepath.Join(dir, "check.sh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inst := &ChallengeInstance{Path: dir}
+
+	tests := []struct {
+		name  string
+		exec  string
+		input string
+		want  bool
+	}{
+		{"accepted", "./check.sh", "WSS{ok}", true},
+		{"rejected", "./check.sh", "WSS{no}", false},
+		{"input is one argument", "./check.sh", "WSS{ok} extra", false},
+		{"command in PATH", "true", "x", true},
+		{"missing command", "./missing.sh", "WSS{ok}", false},
+	}
+	for _, tt := range tests {
+		f := FlagSpec{Exec: tt.exec, Match: matchExec}
+		if got := inst.matchFlag(context.Background(), f, tt.input); got != tt.want {
+			t.Errorf("%s: matchFlag(%q) = %v, want %v", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
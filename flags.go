@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Ways a submission can be checked against a flag.
+const (
+	matchExact  = "exact"  // same text, same case
+	matchIExact = "iexact" // same text, any case (the default)
+	matchRegex  = "regex"  // Value is a regular expression the whole submission must match
+	matchExec   = "exec"   // Exec is run with the submission and accepts it on exit code 0
+)
+
+// Where exec validators run.
+const (
+	execInDirectory = "directory" // on the host, in the challenge directory (the default)
+	execInContainer = "container" // inside the running challenge container
+)
+
+// FlagSpec is an accepted answer for a challenge. In challenge.json it is
+// written either as the plaintext flag or as an object:
+//
+//	"flag": "WSS{example}"
+//	"flag": {"sha256": "<hex>", "salt": "<salt>"}
+//	"flag": {"value": "WSS{Example}", "match": "exact"}
+//	"flag": {"value": "CVE-\\d{4}-\\d+", "match": "regex"}
+//	"flag": {"exec": "./check.sh", "match": "exec", "in": "container"}
+//
+// Hashed flags keep players reading the manifest from learning the answer.
+// The hash is the SHA-256 of the salt followed by the flag, lowercased
+// unless the match is exact.
+//
+// Objects may also describe the objective the flag completes, which is
+// shown to the player when it is found:
+//
+//	{"id": "pilot", "label": "Pilot report found", "points": 100, "value": "WSS{...}"}
+type FlagSpec struct {
+	// Objective metadata. Flags with an Order are only accepted once every
+	// flag with a lower Order has been found.
+	ID      string `json:"id,omitempty"`
+	Label   string `json:"label,omitempty"`
+	Points  int    `json:"points,omitempty"`
+	Message string `json:"message,omitempty"`
+	Order   int    `json:"order,omitempty"`
+
+	Value  string `json:"value,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Salt   string `json:"salt,omitempty"`
+	Match  string `json:"match,omitempty"`
+
+	// Exec validators: the command, split like a shell would, where it
+	// runs and, for compose challenges, in which service's container.
+	Exec    string `json:"exec,omitempty"`
+	In      string `json:"in,omitempty"`
+	Service string `json:"service,omitempty"`
+}
+
+func (f *FlagSpec) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*f = FlagSpec{}
+		return json.Unmarshal(data, &f.Value)
+	}
+	type object FlagSpec
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var o object
+	if err := dec.Decode(&o); err != nil {
+		return err
+	}
+	*f = FlagSpec(o)
+	return f.check()
+}
+
+func (f FlagSpec) MarshalJSON() ([]byte, error) {
+	if f == (FlagSpec{Value: f.Value}) {
+		return json.Marshal(f.Value)
+	}
+	type object FlagSpec
+	return json.Marshal(object(f))
+}
+
+// check reports flag objects whose fields do not fit their match mode.
+func (f FlagSpec) check() error {
+	switch f.mode() {
+	case matchExact, matchIExact:
+		if (f.Value == "") == (f.SHA256 == "") {
+			return errors.New("flag needs either a value or a sha256")
+		}
+	case matchRegex:
+		if f.Value == "" || f.SHA256 != "" {
+			return errors.New("regex flag needs a value holding the pattern")
+		}
+		if _, err := f.regexp(); err != nil {
+			return fmt.Errorf("invalid flag pattern: %w", err)
+		}
+	case matchExec:
+		args, err := splitShellWords(f.Exec)
+		if err != nil {
+			return fmt.Errorf("invalid exec command: %w", err)
+		}
+		if len(args) == 0 {
+			return errors.New("exec flag needs an exec command")
+		}
+	default:
+		return fmt.Errorf("unknown flag match '%s'", f.Match)
+	}
+	if f.mode() != matchExec && (f.Exec != "" || f.In != "" || f.Service != "") {
+		return fmt.Errorf("exec, in and service are only used with match '%s'", matchExec)
+	}
+	if f.In != "" && f.In != execInDirectory && f.In != execInContainer {
+		return fmt.Errorf("unknown exec location '%s'", f.In)
+	}
+	return nil
+}
+
+// mode returns how submissions are checked against the flag.
+func (f FlagSpec) mode() string {
+	if f.Match == "" {
+		return matchIExact
+	}
+	return f.Match
+}
+
+// IsZero reports whether no flag was given.
+func (f FlagSpec) IsZero() bool {
+	return f == FlagSpec{}
+}
+
+// Hashed reports whether the flag is stored as a hash.
+func (f FlagSpec) Hashed() bool {
+	return f.SHA256 != ""
+}
+
+// Plaintext reports whether the flag itself is written in the manifest,
+// as opposed to a hash, a pattern or a validator.
+func (f FlagSpec) Plaintext() bool {
+	m := f.mode()
+	return f.Value != "" && (m == matchExact || m == matchIExact)
+}
+
+// Matches reports whether input is accepted by this flag. Exec flags need a
+// running challenge and are checked by ChallengeInstance.matchFlag instead;
+// Matches never accepts them. Literal comparisons take the same time
+// wherever the input first differs.
+func (f FlagSpec) Matches(input string) bool {
+	switch f.mode() {
+	case matchRegex:
+		re, err := f.regexp()
+		return err == nil && re.MatchString(input)
+	case matchExec:
+		return false
+	}
+	if f.Hashed() {
+		want, err := hex.DecodeString(f.SHA256)
+		if err != nil {
+			return false
+		}
+		got := f.digest(f.Salt, input)
+		return subtle.ConstantTimeCompare(got[:], want) == 1
+	}
+	if f.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(f.fold(input)), []byte(f.fold(f.Value))) == 1
+}
+
+// regexp compiles the pattern of a regex flag, anchored so that it has to
+// match the whole submission.
+func (f FlagSpec) regexp() (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + f.Value + `)$`)
+}
+
+// fold lowercases s unless the flag is matched exactly.
+func (f FlagSpec) fold(s string) string {
+	if f.mode() == matchExact {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+// digest hashes a flag the way hashed FlagSpecs store it.
+func (f FlagSpec) digest(salt, flag string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(salt + f.fold(flag)))
+}
+
+// title describes the objective a flag completes, for the player, with the
+// points it gave.
+func (f FlagSpec) title(points int) string {
+	title := f.Label
+	if title == "" {
+		title = "Flag encontrada"
+	}
+	if points != 0 {
+		title += fmt.Sprintf(" (+%d)", points)
+	}
+	return title
+}
+
+// flagUnlocked reports whether flags[i] can be accepted given the flags
+// found so far: every flag ordered before it must have been found.
+func flagUnlocked(flags []FlagSpec, found map[int]bool, i int) bool {
+	if flags[i].Order == 0 {
+		return true
+	}
+	for j, f := range flags {
+		if f.Order != 0 && f.Order < flags[i].Order && !found[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// hashFlag turns a plaintext flag into its hashed form with a fresh salt.
+// Flags that are not plaintext are returned unchanged.
+func hashFlag(f FlagSpec) (FlagSpec, error) {
+	if !f.Plaintext() {
+		return f, nil
+	}
+	saltBytes := make([]byte, 16)
+	if _, err := rand.Read(saltBytes); err != nil {
+		return f, fmt.Errorf("could not generate salt: %w", err)
+	}
+	salt := hex.EncodeToString(saltBytes)
+	digest := f.digest(salt, f.Value)
+	hashed := f
+	hashed.Value, hashed.SHA256, hashed.Salt = "", hex.EncodeToString(digest[:]), salt
+	return hashed, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import "testing"
+
+func TestHashedFlagMatches(t *testing.T) {
+	iexact, err := hashFlag(FlagSpec{Value: "WSS{Secret}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exact, err := hashFlag(FlagSpec{Value: "WSS{Secret}", Match: matchExact})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iexact.Value != "" || !iexact.Hashed() || iexact.Salt == "" {
+		t.Fatalf("hashFlag = %+v, want a salted hash without the value", iexact)
+	}
+	if iexact.Salt == exact.Salt {
+		t.Error("two hashed flags share a salt")
+	}
+	wrongSalt := iexact
+	wrongSalt.Salt += "0"
+	badHex := iexact
+	badHex.SHA256 = "not hex"
+
+	tests := []struct {
+		name  string
+		flag  FlagSpec
+		input string
+		want  bool
+	}{
+		{"iexact same", iexact, "WSS{Secret}", true},
+		{"iexact other case", iexact, "wss{SECRET}", true},
+		{"iexact wrong", iexact, "WSS{Secre}", false},
+		{"iexact trailing space", iexact, "WSS{Secret} ", false},
+		{"iexact empty", iexact, "", false},
+		{"exact same", exact, "WSS{Secret}", true},
+		{"exact other case", exact, "wss{secret}", false},
+		{"wrong salt", wrongSalt, "WSS{Secret}", false},
+		{"invalid hash", badHex, "WSS{Secret}", false},
+	}
+	for _, tt := range tests {
+		if got := tt.flag.Matches(tt.input); got != tt.want {
+			t.Errorf("%s: Matches(%q) = %v, want %v", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestHashFlagKeepsOtherFlags(t *testing.T) {
+	hashed, err := hashFlag(FlagSpec{Value: "WSS{x}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []FlagSpec{
+		{Value: `WSS\{\d+\}`, Match: matchRegex},
+		{Exec: "./check.sh", Match: matchExec},
+		hashed,
+	} {
+		got, err := hashFlag(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != f {
+			t.Errorf("hashFlag(%+v) = %+v, want it unchanged", f, got)
+		}
+	}
+}
+
+
+func TestFlagMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  FlagSpec
+		input string
+		want  bool
+	}{
+		{"iexact same", FlagSpec{Value: "WSS{Flag}"}, "WSS{Flag}", true},
+		{"iexact other case", FlagSpec{Value: "WSS{Flag}"}, "wss{flag}", true},
+		{"iexact prefix", FlagSpec{Value: "WSS{Flag}"}, "WSS{Fla", false},
+		{"iexact longer", FlagSpec{Value: "WSS{Flag}"}, "WSS{Flag}x", false},
+		{"exact same", FlagSpec{Value: "WSS{Flag}", Match: matchExact}, "WSS{Flag}", true},
+		{"exact other case", FlagSpec{Value: "WSS{Flag}", Match: matchExact}, "wss{flag}", false},
+		{"regex match", FlagSpec{Value: `CVE-\d{4}-\d+`, Match: matchRegex}, "CVE-2021-44228", true},
+		{"regex is anchored at the start", FlagSpec{Value: `CVE-\d{4}-\d+`, Match: matchRegex}, "xCVE-2021-44228", false},
+		{"regex is anchored at the end", FlagSpec{Value: `CVE-\d{4}-\d+`, Match: matchRegex}, "CVE-2021-44228x", false},
+		{"regex alternation stays anchored", FlagSpec{Value: `a|b`, Match: matchRegex}, "ab", false},
+		{"exec never matches", FlagSpec{Exec: "true", Match: matchExec}, "anything", false},
+		{"no value", FlagSpec{}, "", false},
+	}
+	for _, tt := range tests {
+		if got := tt.flag.Matches(tt.input); got != tt.want {
+			t.Errorf("%s: Matches(%q) = %v, want %v", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFlagCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  FlagSpec
+		valid bool
+	}{
+		{"value", FlagSpec{Value: "WSS{x}"}, true},
+		{"hash", FlagSpec{SHA256: "00", Salt: "s"}, true},
+		{"value and hash", FlagSpec{Value: "WSS{x}", SHA256: "00"}, false},
+		{"neither value nor hash", FlagSpec{Match: matchExact}, false},
+		{"regex", FlagSpec{Value: "a+", Match: matchRegex}, true},
+		{"invalid regex", FlagSpec{Value: "(", Match: matchRegex}, false},
+		{"hashed regex", FlagSpec{Value: "a+", SHA256: "00", Match: matchRegex}, false},
+		{"exec", FlagSpec{Exec: "./check.sh", Match: matchExec, In: execInContainer}, true},
+		{"exec without command", FlagSpec{Match: matchExec}, false},
+		{"exec with unknown location", FlagSpec{Exec: "./check.sh", Match: matchExec, In: "host"}, false},
+		{"exec fields without exec match", FlagSpec{Value: "WSS{x}", Exec: "./check.sh"}, false},
+		{"unknown match", FlagSpec{Value: "WSS{x}", Match: "fuzzy"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.flag.check(); (err == nil) != tt.valid {
+			t.Errorf("%s: check() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// runHashFlags implements the hash-flags command: it replaces the plaintext
+// flags of the selected challenges with salted hashes, rewriting each
+// challenge.json in place. It returns the process exit code.
+func runHashFlags(root string, slugs []string, dryRun bool) int {
+	config, err := loadConfig(root)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return 1
+	}
+	selected, err := selectChallenges(config, slugs)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return 2
+	}
+
+	status := 0
+	for _, dirName := range selected {
+		manifestPath := filepath.Join(config.ChallengePath(dirName), "challenge.json")
+		count, err := hashManifestFlags(manifestPath, dryRun)
+		switch {
+		case err != nil:
+			log.Printf("Error: %s: %v", manifestPath, err)
+			status = 1
+		case count == 0:
+			fmt.Printf("%s: nenhuma flag em texto puro.\n", dirName)
+		case dryRun:
+			fmt.Printf("%s: %d flag(s) seriam convertidas.\n", dirName, count)
+		default:
+			fmt.Printf("%s: %d flag(s) convertidas para hash.\n", dirName, count)
+		}
+	}
+	return status
+}
+
+// hashManifestFlags hashes the plaintext entries of "flag" and "flags" in a
+// challenge.json. Every other field is kept as written and in its original
+// order. It returns how many flags were hashed.
+func hashManifestFlags(manifestPath string, dryRun bool) (int, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return 0, err
+	}
+	// Make sure the manifest is valid before touching it.
+	var challenge Challenge
+	if err := decodeStrict(data, &challenge); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	hash := func(f FlagSpec) (FlagSpec, error) {
+		if !f.Plaintext() {
+			return f, nil
+		}
+		count++
+		return hashFlag(f)
+	}
+
+	out, err := rewriteJSONObject(data, func(key string, raw json.RawMessage) (any, error) {
+		switch key {
+		case "flag":
+			return hash(challenge.Flag)
+		case "flags":
+			flags := make([]FlagSpec, len(challenge.Flags))
+			for i, f := range challenge.Flags {
+				if flags[i], err = hash(f); err != nil {
+					return nil, err
+				}
+			}
+			return flags, nil
+		}
+		return raw, nil
+	})
+	if err != nil || count == 0 || dryRun {
+		return count, err
+	}
+	return count, os.WriteFile(manifestPath, out, 0o644)
+}
+
+// rewriteJSONObject re-encodes a JSON object, keeping its keys in their
+// original order and replacing each value with what fn returns for it.
+func rewriteJSONObject(data []byte, fn func(key string, raw json.RawMessage) (any, error)) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	first := true
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		value, err := fn(key, raw)
+		if err != nil {
+			return nil, err
+		}
+
+		var encoded bytes.Buffer
+		enc := json.NewEncoder(&encoded)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(map[string]any{key: value}); err != nil {
+			return nil, err
+		}
+		// Strip the braces of the one-entry object, keeping "key": value.
+		entry := bytes.TrimSpace(encoded.Bytes())
+		entry = entry[1 : len(entry)-1]
+
+		if !first {
+			buf.WriteString(",")
+		}
+		first = false
+		buf.Write(entry)
+	}
+
+	buf.WriteString("}")
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteString("\n")
+	return out.Bytes(), nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Several instances of a challenge can run side by side on one host, for
+// different players or sessions. Each instance names its containers,
+// networks and volumes after its session id, and gets host ports of its own.
+
+// instanceName suffixes a Docker resource name with the instance id. Names
+// are left as they are outside a session, for example when building images.
+func (inst *ChallengeInstance) instanceName(name string) string {
+	if inst.SessionID == "" {
+		return name
+	}
+	return name + "-" + inst.SessionID
+}
+
+// allocatePorts picks the host ports this instance publishes in place of
+// the declared ones.
+func (inst *ChallengeInstance) allocatePorts(declared []int) error {
+	ports, err := hostPorts.allocate(inst.SessionID, declared)
+	if err != nil {
+		return err
+	}
+	inst.ports = ports
+	for _, port := range declared {
+		if host := ports[port]; host != port && inst.verbose() {
+			fmt.Printf("Port %d is in use; publishing it on port %d instead.\n", port, host)
+		}
+	}
+	return nil
+}
+
+// hostPort returns the host port allocated for a declared port, or the
+// declared port itself when none was.
+func (inst *ChallengeInstance) hostPort(declared int) int {
+	if port, ok := inst.ports[declared]; ok {
+		return port
+	}
+	return declared
+}
+
+// releasePorts gives the instance's host ports back once it is torn down.
+func (inst *ChallengeInstance) releasePorts() {
+	hostPorts.release(inst.SessionID)
+	inst.ports = nil
+}
+
+// hostPorts hands out the host ports of the instances run by this process.
+var hostPorts = &portAllocator{owners: make(map[int]string)}
+
+// portAllocator keeps track of the host ports given to running instances,
+// so that two instances never ask Docker for the same one.
+type portAllocator struct {
+	mu     sync.Mutex
+	owners map[int]string // host port -> session id
+}
+
+// allocate picks a host port for each declared port: the declared port
+// itself when it is free, or else one chosen by the operating system.
+func (a *portAllocator) allocate(owner string, declared []int) (map[int]int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ports := make(map[int]int, len(declared))
+	for _, port := range declared {
+		if _, ok := ports[port]; ok {
+			continue
+		}
+		host := port
+		if _, taken := a.owners[host]; taken || !portFree(host) {
+			var err error
+			if host, err = a.freePort(); err != nil {
+				a.releaseLocked(owner)
+				return nil, err
+			}
+		}
+		a.owners[host] = owner
+		ports[port] = host
+	}
+	return ports, nil
+}
+
+// freePort asks the operating system for a port nobody listens on and no
+// instance was given yet.
+func (a *portAllocator) freePort() (int, error) {
+	for range 10 {
+		ln, err := net.Listen("tcp", ":0")
+		if err != nil {
+			return 0, fmt.Errorf("could not find a free host port: %w", err)
+		}
+		port := ln.Addr().(*net.TCPAddr).Port
+		ln.Close()
+		if _, taken := a.owners[port]; !taken {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("could not find a free host port")
+}
+
+// release frees every port given to owner.
+func (a *portAllocator) release(owner string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.releaseLocked(owner)
+}
+
+func (a *portAllocator) releaseLocked(owner string) {
+	for port, o := range a.owners {
+		if o == owner {
+			delete(a.owners, port)
+		}
+	}
+}
+
+// portFree reports whether nothing listens on a host port, including
+// containers of other platform processes.
+func portFree(port int) bool {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Types of journal events.
+const (
+	eventChallengeStart = "challenge_start"
+	eventChallengeStop  = "challenge_stop"
+	eventBuild          = "build"
+	eventPull           = "pull"
+	eventContainerStart = "container_start"
+	eventHint           = "hint"
+	eventFlag           = "flag_submitted"
+	eventFlagGenerated  = "flag_generated"
+	eventQuit           = "quit"
+	eventTimeout        = "timeout"
+	eventExpired        = "expired"
+)
+
+// Results of a flag submission.
+const (
+	flagCorrect   = "correct"
+	flagWrong     = "wrong"
+	flagDuplicate = "duplicate"
+	flagLocked    = "locked"
+	flagThrottled = "throttled"
+)
+
+// Event is a line of the session journal, which keeps what players did so
+// that instructors can review it afterwards.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Session   string    `json:"session,omitempty"`
+	Challenge string    `json:"challenge,omitempty"`
+	// Service is the compose service a build or container event is about.
+	Service string `json:"service,omitempty"`
+	// Hint is the number of a revealed hint, starting at 1.
+	Hint int `json:"hint,omitempty"`
+	// Input is the SHA-256 of a submitted flag, or of the flag generated
+	// for the session, so that answers can be compared without being
+	// stored.
+	Input string `json:"input_sha256,omitempty"`
+	// Result is the result of a flag submission, or the final state of a
+	// challenge session.
+	Result string `json:"result,omitempty"`
+	// Flag identifies the flag a correct submission found.
+	Flag  string `json:"flag,omitempty"`
+	Score *int   `json:"score,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// journalPath returns where a player's journal is kept.
+func journalPath(player string) (string, error) {
+	return playerPath(player, "journal.jsonl")
+}
+
+// journalMu serializes writes to the journal; journalWarned keeps a broken
+// journal from flooding the terminal with warnings.
+var (
+	journalMu     sync.Mutex
+	journalWarned bool
+)
+
+// recordEvent appends an event to a player's journal. Failures are logged
+// once and otherwise ignored: the journal must never get in the player's
+// way.
+func recordEvent(player string, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if err := appendEvent(player, e); err != nil && !journalWarned {
+		journalWarned = true
+		log.Printf("Warning: could not write to the journal: %v", err)
+	}
+}
+
+func appendEvent(player string, e Event) error {
+	path, err := journalPath(player)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// event records an event of this instance's session.
+func (inst *ChallengeInstance) event(e Event) {
+	e.Session, e.Challenge = inst.SessionID, inst.DirName
+	recordEvent(inst.player(), e)
+}
+
+// newSessionID returns a random identifier for a challenge session.
+func newSessionID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// inputHash hashes a submitted flag for the journal.
+func inputHash(input string) string {
+	sum := sha256.Sum256([]byte(input))
+	return hex.EncodeToString(sum[:])
+}
+
+// journalFilter selects journal events.
+type journalFilter struct {
+	Challenge string
+	Session   string
+	Types     []string
+	Since     time.Time
+}
+
+func (f journalFilter) match(e Event) bool {
+	return (f.Challenge == "" || e.Challenge == f.Challenge) &&
+		(f.Session == "" || strings.HasPrefix(e.Session, f.Session)) &&
+		(len(f.Types) == 0 || slices.Contains(f.Types, e.Type)) &&
+		!e.Time.Before(f.Since)
+}
+
+// readJournal returns the events of the journal at path that match the
+// filter, in the order they were written. A missing journal has no events.
+func readJournal(path string, filter journalFilter) ([]Event, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			log.Printf("Warning: %s:%d: skipping invalid event: %v", path, line, err)
+			continue
+		}
+		if filter.match(e) {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
+
+// parseSince accepts either a duration back from now, such as "24h", or a
+// date such as "2025-03-01".
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since '%s': use a duration such as 24h or a date such as 2006-01-02", s)
+}
+
+// printEvents lists events one per line.
+func printEvents(events []Event) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "HORA\tSESSÃO\tDESAFIO\tEVENTO\tDETALHES")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Session, e.Challenge, e.Type, e.details())
+	}
+	w.Flush()
+}
+
+// details summarizes the fields specific to an event.
+func (e Event) details() string {
+	var parts []string
+	add := func(format string, args ...any) { parts = append(parts, fmt.Sprintf(format, args...)) }
+	if e.Service != "" {
+		add("serviço=%s", e.Service)
+	}
+	if e.Hint != 0 {
+		add("dica=%d", e.Hint)
+	}
+	if e.Result != "" {
+		add("resultado=%s", e.Result)
+	}
+	if e.Flag != "" {
+		add("flag=%s", e.Flag)
+	}
+	if input := e.Input; input != "" {
+		// The journal can be edited by hand, so the hash may be short.
+		if len(input) > 12 {
+			input = input[:12]
+		}
+		add("entrada=%s", input)
+	}
+	if e.Score != nil {
+		add("pontos=%d", *e.Score)
+	}
+	if e.Error != "" {
+		add("erro=%q", e.Error)
+	}
+	return strings.Join(parts, " ")
+}
+
+// journalSummary is what happened in one challenge across sessions.
+type journalSummary struct {
+	Sessions map[string]bool
+	Solved   int
+	Correct  int
+	Wrong    int
+	Hints    int
+	Best     *int
+}
+
+// summarizeJournal prints, per challenge, how many sessions were played,
+// how many solved it, the flags submitted and the hints revealed.
+func summarizeJournal(events []Event) {
+	summaries := make(map[string]*journalSummary)
+	for _, e := range events {
+		if e.Challenge == "" {
+			continue
+		}
+		s := summaries[e.Challenge]
+		if s == nil {
+			s = &journalSummary{Sessions: make(map[string]bool)}
+			summaries[e.Challenge] = s
+		}
+		if e.Session != "" {
+			s.Sessions[e.Session] = true
+		}
+		switch e.Type {
+		case eventFlag:
+			switch e.Result {
+			case flagCorrect:
+				s.Correct++
+			case flagWrong:
+				s.Wrong++
+			}
+		case eventHint:
+			s.Hints++
+		case eventChallengeStop:
+			if e.Result == strings.ToLower(StateSolved.String()) || e.Result == strings.ToLower(StateKeptAlive.String()) {
+				s.Solved++
+			}
+			if e.Score != nil && (s.Best == nil || *e.Score > *s.Best) {
+				s.Best = e.Score
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DESAFIO\tSESSÕES\tRESOLVIDO\tACERTOS\tERROS\tDICAS\tMELHOR PONTUAÇÃO")
+	for _, name := range sortedKeys(summaries) {
+		s := summaries[name]
+		best := "-"
+		if s.Best != nil {
+			best = fmt.Sprint(*s.Best)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n", name, len(s.Sessions), s.Solved, s.Correct, s.Wrong, s.Hints, best)
+	}
+	w.Flush()
+}
+
+// runJournal implements the journal command and returns the process exit
+// code.
+func runJournal(filter journalFilter, summary, asJSON bool) int {
+	path, err := journalPath(activePlayer)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return 1
+	}
+	events, err := readJournal(path, filter)
+	if err != nil {
+		log.Printf("Error: could not read the journal: %v", err)
+		return 1
+	}
+	switch {
+	case asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range events {
+			enc.Encode(e)
+		}
+	case len(events) == 0:
+		fmt.Println("Nenhum evento encontrado.")
+	case summary:
+		summarizeJournal(events)
+	default:
+		printEvents(events)
+	}
+	return 0
+}
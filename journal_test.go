@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestEventDetails(t *testing.T) {
+	score := 80
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"empty", Event{}, ""},
+		{"full hash", Event{Result: flagWrong, Input: inputHash("WSS{x}")}, "resultado=wrong entrada=" + inputHash("WSS{x}")[:12]},
+		{"short hash", Event{Input: "abc"}, "entrada=abc"},
+		{"hint and score", Event{Hint: 2, Score: &score}, "dica=2 pontos=80"},
+		{"error", Event{Service: "web", Error: "exit 1"}, `serviço=web erro="exit 1"`},
+	}
+	for _, tt := range tests {
+		if got := tt.event.details(); got != tt.want {
+			t.Errorf("%s: details() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
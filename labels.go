@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+)
+
+// Labels attached to the Docker resources the platform creates, so they can
+// be found again without knowing their names.
+const (
+	labelChallenge = "wss-ctf.challenge"
+	labelProject   = "wss-ctf.project"
+	labelService   = "wss-ctf.service"
+	labelSession   = "wss-ctf.session"
+	labelExpires   = "wss-ctf.expires" // RFC 3339 time the instance's TTL runs out
+)
+
+// resourceLabels returns the labels for a resource of this instance, merged
+// with any extra labels given.
+func (inst *ChallengeInstance) resourceLabels(extra map[string]string) map[string]string {
+	labels := map[string]string{labelChallenge: inst.DirName}
+	maps.Copy(labels, extra)
+	return labels
+}
+
+// instanceLabels returns resourceLabels plus the labels that tie a
+// resource to this instance and say when it expires. Images are shared by
+// every instance and do not get them, unless the dynamic flag is baked in.
+func (inst *ChallengeInstance) instanceLabels(extra map[string]string) map[string]string {
+	labels := inst.resourceLabels(extra)
+	if inst.SessionID != "" {
+		labels[labelSession] = inst.SessionID
+	}
+	if !inst.expires.IsZero() {
+		labels[labelExpires] = inst.expires.UTC().Format(time.RFC3339)
+	}
+	return labels
+}
+
+// labelFilter builds a filter matching resources that carry every given label.
+func labelFilter(labels map[string]string) filters.Args {
+	args := filters.NewArgs()
+	for _, k := range sortedKeys(labels) {
+		args.Add("label", k+"="+labels[k])
+	}
+	return args
+}
+
+// removedResources counts the Docker resources removed by label.
+type removedResources struct {
+	Containers, Networks, Volumes, Images int
+}
+
+// removeLabeledResources removes the containers, networks and volumes
+// matching the filter, and its images too when images is set, in the
+// order Docker lets them go.
+func removeLabeledResources(ctx context.Context, cli *client.Client, args filters.Args, images, debug bool) (removedResources, error) {
+	var removed removedResources
+	var err error
+	if removed.Containers, err = removeLabeledContainers(ctx, cli, args, debug); err != nil {
+		return removed, err
+	}
+	if removed.Networks, err = removeLabeledNetworks(ctx, cli, args); err != nil {
+		return removed, err
+	}
+	if removed.Volumes, err = removeLabeledVolumes(ctx, cli, args); err != nil {
+		return removed, err
+	}
+	if images {
+		removed.Images, err = removeLabeledImages(ctx, cli, args)
+	}
+	return removed, err
+}
+
+// removeLabeledContainers stops and removes every container matching the
+// filter, and returns how many were removed.
+func removeLabeledContainers(ctx context.Context, cli *client.Client, args filters.Args, debug bool) (int, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return 0, fmt.Errorf("could not list containers: %w", err)
+	}
+	removed := 0
+	for _, c := range containers {
+		if err := removeContainer(ctx, cli, c.ID, debug); err != nil {
+			log.Printf("Warning: could not remove container %s: %v", c.ID, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// removeContainer stops a container, giving it a few seconds to exit, and
+// removes it.
+func removeContainer(ctx context.Context, cli *client.Client, name string, debug bool) error {
+	if debug {
+		fmt.Printf("Removing container %s...\n", name)
+	}
+	timeout := 3 // seconds
+	// It may already be stopped; removing it with Force covers both cases.
+	cli.ContainerStop(ctx, name, container.StopOptions{Timeout: &timeout})
+	return cli.ContainerRemove(ctx, name, container.RemoveOptions{Force: true})
+}
+
+// removeLabeledNetworks removes every network matching the filter, and
+// returns how many were removed.
+func removeLabeledNetworks(ctx context.Context, cli *client.Client, args filters.Args) (int, error) {
+	networks, err := cli.NetworkList(ctx, network.ListOptions{Filters: args})
+	if err != nil {
+		return 0, fmt.Errorf("could not list networks: %w", err)
+	}
+	removed := 0
+	for _, n := range networks {
+		if err := cli.NetworkRemove(ctx, n.ID); err != nil {
+			log.Printf("Warning: could not remove network %s: %v", n.Name, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// removeLabeledVolumes removes every volume matching the filter, and
+// returns how many were removed.
+func removeLabeledVolumes(ctx context.Context, cli *client.Client, args filters.Args) (int, error) {
+	resp, err := cli.VolumeList(ctx, volume.ListOptions{Filters: args})
+	if err != nil {
+		return 0, fmt.Errorf("could not list volumes: %w", err)
+	}
+	removed := 0
+	for _, v := range resp.Volumes {
+		if err := cli.VolumeRemove(ctx, v.Name, true); err != nil {
+			log.Printf("Warning: could not remove volume %s: %v", v.Name, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// removeLabeledImages removes every image matching the filter, and returns
+// how many were removed.
+func removeLabeledImages(ctx context.Context, cli *client.Client, args filters.Args) (int, error) {
+	images, err := cli.ImageList(ctx, image.ListOptions{Filters: args})
+	if err != nil {
+		return 0, fmt.Errorf("could not list images: %w", err)
+	}
+	removed := 0
+	for _, img := range images {
+		if _, err := cli.ImageRemove(ctx, img.ID, image.RemoveOptions{Force: true}); err != nil {
+			log.Printf("Warning: could not remove image %s: %v", img.ID, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
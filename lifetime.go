@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+)
+
+// LifetimePolicy bounds how long a challenge instance runs. config.json sets
+// it for every challenge and challenge.json for one of them; fields left
+// out fall back to config.json, then to the defaults. A duration of 0
+// turns the bound off.
+type LifetimePolicy struct {
+	// TTL is how long an instance may run in total.
+	TTL *Duration `json:"ttl,omitempty"`
+	// IdleTimeout is how long an instance may run without the player
+	// using it, at the prompt or in the web UI.
+	IdleTimeout *Duration `json:"idle_timeout,omitempty"`
+}
+
+// Lifetime defaults, used when neither the challenge nor config.json set
+// them. The idle timeout is off: the platform only sees the player at the
+// prompt or in the web UI, not while they work on the challenge itself.
+const (
+	defaultTTL         = 4 * time.Hour
+	defaultIdleTimeout = 0
+)
+
+// lifetime returns the TTL and idle timeout of a challenge's instances; 0
+// means the instances are not bounded by it.
+func (c Config) lifetime(challenge Challenge) (ttl, idleTimeout time.Duration) {
+	pick := func(fallback time.Duration, values ...*Duration) time.Duration {
+		for _, v := range values {
+			if v != nil {
+				return time.Duration(*v)
+			}
+		}
+		return fallback
+	}
+	ttl = pick(defaultTTL, challenge.Lifetime.TTL, c.Lifetime.TTL)
+	idleTimeout = pick(defaultIdleTimeout, challenge.Lifetime.IdleTimeout, c.Lifetime.IdleTimeout)
+	return ttl, idleTimeout
+}
+
+// expiryMessage tells the player why an instance was stopped before they
+// left it; why is expiryTTL or expiryIdle.
+func expiryMessage(inst *ChallengeInstance, why string) string {
+	if why == expiryIdle {
+		return fmt.Sprintf("⌛ Desafio encerrado após %s sem atividade. Seu progresso foi salvo.", inst.idleTimeout)
+	}
+	return fmt.Sprintf("⌛ O ambiente atingiu o tempo máximo de %s e foi encerrado. Seu progresso foi salvo.", inst.ttl)
+}
+
+// Each process keeps a lease on the instances it runs: a file in the state
+// directory it touches every reapInterval. When a process dies without
+// stopping its instances, for example because its terminal was closed, the
+// leases go stale and the reaper of the next platform process removes them.
+const (
+	reapInterval = time.Minute
+	leaseTimeout = 5 * time.Minute
+)
+
+// leases holds the session ids of the instances this process runs.
+var leases = struct {
+	sync.Mutex
+	held map[string]bool
+}{held: make(map[string]bool)}
+
+// leasePath returns the lease file of an instance.
+func leasePath(session string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "instances", session), nil
+}
+
+// holdLease marks the instance as run by this process.
+func (inst *ChallengeInstance) holdLease() {
+	leases.Lock()
+	leases.held[inst.SessionID] = true
+	leases.Unlock()
+	touchLease(inst.SessionID)
+}
+
+// dropLease gives up the instance's lease once it is torn down.
+func (inst *ChallengeInstance) dropLease() {
+	leases.Lock()
+	delete(leases.held, inst.SessionID)
+	leases.Unlock()
+	removeLease(inst.SessionID)
+}
+
+// touchLease creates or refreshes a lease file.
+func touchLease(session string) {
+	path, err := leasePath(session)
+	if err != nil {
+		log.Printf("Warning: could not record instance lease: %v", err)
+		return
+	}
+	now := time.Now()
+	err = os.Chtimes(path, now, now)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			err = os.WriteFile(path, nil, 0o644)
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: could not record instance lease: %v", err)
+	}
+}
+
+func removeLease(session string) {
+	path, err := leasePath(session)
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Warning: could not remove instance lease: %v", err)
+	}
+}
+
+// leaseAge returns how long ago a lease was refreshed. It reports false
+// when there is no lease file, as for instances run by another user.
+func leaseAge(session string, now time.Time) (time.Duration, bool) {
+	path, err := leasePath(session)
+	if err != nil {
+		return 0, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(info.ModTime()), true
+}
+
+// startReaper refreshes the leases of this process and removes expired
+// instances in the background, until ctx is done.
+func startReaper(ctx context.Context, cli *client.Client, debug bool) {
+	go func() {
+		ticker := time.NewTicker(reapInterval)
+		defer ticker.Stop()
+		for {
+			reap(ctx, cli, time.Now(), debug)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// reap refreshes the leases of this process, then removes the instances of
+// other processes that reached their TTL or whose lease went stale. The
+// instances of this process are stopped by their own sessions.
+func reap(ctx context.Context, cli *client.Client, now time.Time, debug bool) {
+	leases.Lock()
+	held := sortedKeys(leases.held)
+	leases.Unlock()
+	for _, session := range held {
+		touchLease(session)
+	}
+
+	instances, err := labeledInstances(ctx, cli, filters.NewArgs(filters.Arg("label", labelSession)))
+	if err != nil {
+		if debug {
+			log.Printf("Warning: could not look for expired instances: %v", err)
+		}
+		return
+	}
+	for _, session := range sortedKeys(instances) {
+		if slices.Contains(held, session) {
+			continue
+		}
+		labels := instances[session]
+		why := ""
+		if expires, err := time.Parse(time.RFC3339, labels[labelExpires]); err == nil && now.After(expires) {
+			why = "TTL reached"
+		} else if age, ok := leaseAge(session, now); ok && age > leaseTimeout {
+			why = "lease went stale"
+		}
+		if why == "" {
+			continue
+		}
+		if err := removeInstance(ctx, cli, session, debug); err != nil {
+			log.Printf("Warning: could not remove expired instance %s: %v", session, err)
+			continue
+		}
+		removeLease(session)
+		if debug {
+			log.Printf("Removed instance %s of challenge '%s': %s", session, labels[labelChallenge], why)
+		}
+	}
+}
+
+// labeledInstances returns the labels of every instance that has
+// containers, networks or volumes matching the filter, keyed by session id.
+func labeledInstances(ctx context.Context, cli *client.Client, args filters.Args) (map[string]map[string]string, error) {
+	instances := make(map[string]map[string]string)
+	add := func(labels map[string]string) {
+		if session := labels[labelSession]; session != "" && instances[session] == nil {
+			instances[session] = labels
+		}
+	}
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("could not list containers: %w", err)
+	}
+	for _, c := range containers {
+		add(c.Labels)
+	}
+	networks, err := cli.NetworkList(ctx, network.ListOptions{Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("could not list networks: %w", err)
+	}
+	for _, n := range networks {
+		add(n.Labels)
+	}
+	volumes, err := cli.VolumeList(ctx, volume.ListOptions{Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("could not list volumes: %w", err)
+	}
+	for _, v := range volumes.Volumes {
+		add(v.Labels)
+	}
+	return instances, nil
+}
+
+// removeInstance stops and removes the containers, networks and volumes of
+// an instance, and the images built for it alone.
+func removeInstance(ctx context.Context, cli *client.Client, session string, debug bool) error {
+	_, err := removeLabeledResources(ctx, cli, labelFilter(map[string]string{labelSession: session}), true, debug)
+	return err
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+)
+
+// lintMaxFileSize is the largest file scanned for plaintext flags.
+const lintMaxFileSize = 10 << 20
+
+// dockerInstruction is one instruction of a Dockerfile.
+type dockerInstruction struct {
+	Cmd  string // upper-cased instruction name
+	Args []string
+	Line int
+}
+
+// parseDockerfile reads the instructions of a Dockerfile, joining continued
+// lines and skipping comments.
+func parseDockerfile(path string) ([]dockerInstruction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var instructions []dockerInstruction
+	var buf strings.Builder
+	start := 0
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "#") || (text == "" && buf.Len() == 0) {
+			continue
+		}
+		if buf.Len() == 0 {
+			start = line
+		}
+		if strings.HasSuffix(text, "\\") {
+			buf.WriteString(strings.TrimSuffix(text, "\\"))
+			buf.WriteByte(' ')
+			continue
+		}
+		buf.WriteString(text)
+
+		cmd, rest, _ := strings.Cut(buf.String(), " ")
+		buf.Reset()
+		inst := dockerInstruction{Cmd: strings.ToUpper(cmd), Line: start}
+		rest = strings.TrimSpace(rest)
+		// JSON (exec) form, e.g. COPY ["a", "b"]
+		if strings.HasPrefix(rest, "[") && json.Unmarshal([]byte(rest), &inst.Args) == nil {
+			instructions = append(instructions, inst)
+			continue
+		}
+		inst.Args = strings.Fields(rest)
+		instructions = append(instructions, inst)
+	}
+	return instructions, scanner.Err()
+}
+
+// copySources returns the source paths of a COPY or ADD instruction, leaving
+// out its flags and the destination. Sources copied from another build stage
+// or downloaded from a URL are not part of the build context and are skipped.
+func (inst dockerInstruction) copySources() []string {
+	var args []string
+	for _, arg := range inst.Args {
+		if strings.HasPrefix(arg, "--from=") {
+			return nil
+		}
+		if !strings.HasPrefix(arg, "--") {
+			args = append(args, arg)
+		}
+	}
+	if len(args) < 2 {
+		return nil
+	}
+	var sources []string
+	for _, src := range args[:len(args)-1] {
+		if strings.Contains(src, "://") {
+			continue
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+// lintRoot cross-checks every challenge listed in config.json against its
+// Dockerfile or compose file and returns what it finds.
+func lintRoot(root string) []Problem {
+	v := &validator{root: root}
+	config, err := loadConfig(root)
+	if err != nil {
+		v.add(filepath.Join(root, "config.json"), "", "%v (run validate for details)", err)
+		return v.problems
+	}
+	for _, dirName := range config.Challenges {
+		challengePath := config.ChallengePath(dirName)
+		challenge, err := loadChallenge(challengePath)
+		if err != nil {
+			v.add(filepath.Join(challengePath, "challenge.json"), "", "%v (run validate for details)", err)
+			continue
+		}
+		v.lintChallenge(challengePath, challenge)
+		if ttl, _ := config.lifetime(challenge); ttl > 0 && time.Duration(challenge.TimeLimit) > ttl {
+			v.warn(filepath.Join(challengePath, "challenge.json"), "time_limit", "is longer than the instance TTL (%s), which stops the challenge first", ttl)
+		}
+	}
+	return v.problems
+}
+
+// lintChallenge runs every check on a single challenge.
+func (v *validator) lintChallenge(challengePath string, challenge Challenge) {
+	manifestPath := filepath.Join(challengePath, "challenge.json")
+
+	switch detectRuntime(challengePath).(type) {
+	case composeRuntime:
+		v.lintCompose(challengePath, challenge)
+	case dockerfileRuntime:
+		v.lintDockerfile(challengePath, filepath.Join(challengePath, "Dockerfile"))
+		if df := challenge.DynamicFlag; df != nil && df.BuildArg != "" {
+			v.lintBuildArg(filepath.Join(challengePath, "Dockerfile"), df.BuildArg)
+		}
+		if df := challenge.DynamicFlag; df != nil && len(df.Services) > 0 {
+			v.warn(manifestPath, "dynamic_flag.services", "only used by docker-compose.yml challenges")
+		}
+		if len(challenge.Ports) > 1 {
+			v.warn(manifestPath, "ports", "only the first port (%d) is published for Dockerfile challenges", challenge.Ports[0])
+		}
+		if len(challenge.Ports) > 0 {
+			v.lintExpose(challengePath)
+		}
+	}
+
+	// Hints
+	flagCount := max(len(challenge.Flags), 1)
+	switch {
+	case len(challenge.Hints) == 0:
+		v.warn(manifestPath, "hints", "challenge has no hints")
+	case len(challenge.Hints) < flagCount:
+		v.warn(manifestPath, "hints", "%d hint(s) for %d flags; some flags have no hint", len(challenge.Hints), flagCount)
+	}
+
+	if limit, after := challenge.TimeLimit, challenge.Scoring.DecayAfter; limit > 0 && challenge.Scoring.DecayPerMinute > 0 && after >= limit {
+		v.warn(manifestPath, "scoring.decay_after", "is not shorter than time_limit (%s), so flags never lose points", time.Duration(limit))
+	}
+
+	if df := challenge.DynamicFlag; df != nil && !flagPlaceholder.MatchString(df.Template) {
+		v.warn(manifestPath, "dynamic_flag.template", "has no placeholders, so every session gets the same flag")
+	}
+
+	v.lintPlaintextFlags(challengePath, challenge)
+}
+
+// lintDockerfile checks that everything a Dockerfile copies exists in its
+// build context.
+func (v *validator) lintDockerfile(contextPath, dockerfilePath string) {
+	instructions, err := parseDockerfile(dockerfilePath)
+	if err != nil {
+		v.add(dockerfilePath, "", "could not read Dockerfile: %v", err)
+		return
+	}
+	for _, inst := range instructions {
+		if inst.Cmd != "COPY" && inst.Cmd != "ADD" {
+			continue
+		}
+		for _, src := range inst.copySources() {
+			matches, err := filepath.Glob(filepath.Join(contextPath, src))
+			if err != nil || len(matches) == 0 {
+				v.add(dockerfilePath, "line "+strconv.Itoa(inst.Line), "%s source '%s' does not exist in the build context", inst.Cmd, src)
+			}
+		}
+	}
+}
+
+// lintBuildArg warns when a Dockerfile does not declare the build argument
+// the dynamic flag is passed through, since the flag would then be lost.
+func (v *validator) lintBuildArg(dockerfilePath, arg string) {
+	instructions, err := parseDockerfile(dockerfilePath)
+	if err != nil {
+		return
+	}
+	for _, inst := range instructions {
+		if inst.Cmd != "ARG" {
+			continue
+		}
+		for _, a := range inst.Args {
+			if name, _, _ := strings.Cut(a, "="); name == arg {
+				return
+			}
+		}
+	}
+	v.warn(dockerfilePath, "", "no 'ARG %s'; the dynamic flag is passed as this build argument", arg)
+}
+
+// lintExpose checks the Dockerfile's EXPOSE against the container port the
+// platform publishes.
+func (v *validator) lintExpose(challengePath string) {
+	dockerfilePath := filepath.Join(challengePath, "Dockerfile")
+	instructions, err := parseDockerfile(dockerfilePath)
+	if err != nil {
+		return
+	}
+	var exposed []string
+	line := 0
+	for _, inst := range instructions {
+		if inst.Cmd != "EXPOSE" {
+			continue
+		}
+		line = inst.Line
+		for _, arg := range inst.Args {
+			port, _, _ := strings.Cut(arg, "/")
+			if port == strconv.Itoa(challengeContainerPort) {
+				return
+			}
+			exposed = append(exposed, port)
+		}
+	}
+	if len(exposed) == 0 {
+		v.warn(dockerfilePath, "", "no EXPOSE instruction; the platform publishes container port %d", challengeContainerPort)
+		return
+	}
+	v.add(dockerfilePath, "line "+strconv.Itoa(line), "EXPOSE %s, but the platform publishes container port %d", strings.Join(exposed, " "), challengeContainerPort)
+}
+
+// lintCompose checks the compose file against the manifest: published ports,
+// bind mount sources and the Dockerfiles of built services. It also points
+// out the keys the platform ignores.
+func (v *validator) lintCompose(challengePath string, challenge Challenge) {
+	composePath := filepath.Join(challengePath, "docker-compose.yml")
+	manifestPath := filepath.Join(challengePath, "challenge.json")
+	cf, err := loadComposeFile(challengePath)
+	if err != nil {
+		v.add(composePath, "", "%v", err)
+		return
+	}
+	for _, key := range cf.unsupported {
+		v.warn(composePath, key, "is not supported by the platform and is ignored")
+	}
+
+	published := make(map[int]string) // host port -> service
+	for _, name := range sortedKeys(cf.Services) {
+		svc := cf.Services[name]
+		field := "services." + name
+
+		_, bindings, err := nat.ParsePortSpecs(svc.Ports)
+		if err != nil {
+			v.add(composePath, field+".ports", "%v", err)
+		}
+		for _, bs := range bindings {
+			for _, b := range bs {
+				if port, err := strconv.Atoi(b.HostPort); err == nil {
+					published[port] = name
+				}
+			}
+		}
+
+		for _, spec := range svc.Volumes {
+			m, err := parseComposeMount(spec, challengePath)
+			if err != nil {
+				v.add(composePath, field+".volumes", "%v", err)
+				continue
+			}
+			if _, err := os.Stat(m.Source); m.Bind && err != nil {
+				v.add(composePath, field+".volumes", "bind mount source '%s' does not exist", spec)
+			}
+		}
+
+		if svc.Build != nil {
+			contextPath := filepath.Join(challengePath, svc.Build.Context)
+			dockerfile := svc.Build.Dockerfile
+			if dockerfile == "" {
+				dockerfile = "Dockerfile"
+			}
+			v.lintDockerfile(contextPath, filepath.Join(contextPath, dockerfile))
+			if df := challenge.DynamicFlag; df != nil && df.BuildArg != "" && df.appliesTo(name) {
+				v.lintBuildArg(filepath.Join(contextPath, dockerfile), df.BuildArg)
+			}
+		}
+	}
+
+	for i, port := range challenge.Ports {
+		if _, ok := published[port]; !ok {
+			v.add(manifestPath, fmt.Sprintf("ports.%d", i), "port %d is not published by any service in docker-compose.yml", port)
+		}
+	}
+	for _, port := range sortedIntKeys(published) {
+		if !slices.Contains(challenge.Ports, port) {
+			v.warn(composePath, "services."+published[port]+".ports", "port %d is published but not listed in challenge.json", port)
+		}
+	}
+}
+
+// lintPlaintextFlags warns about plaintext flags in the manifest and about
+// files in the challenge directory that contain a flag verbatim. Players can
+// read the challenge directory from the VM, so any of them gives the answer
+// away. Hashed flags cannot be looked for in files.
+func (v *validator) lintPlaintextFlags(challengePath string, challenge Challenge) {
+	// n numbers the flag from its position in flags, or is 0 for flag.
+	type plaintextFlag struct {
+		n     int
+		value string
+	}
+	var flags []plaintextFlag
+	if challenge.Flag.Plaintext() {
+		flags = append(flags, plaintextFlag{0, challenge.Flag.Value})
+	}
+	for i, f := range challenge.Flags {
+		if f.Plaintext() {
+			flags = append(flags, plaintextFlag{i + 1, f.Value})
+		}
+	}
+	if len(flags) > 0 {
+		v.warn(filepath.Join(challengePath, "challenge.json"), "", "%d flag(s) stored in plain text; run hash-flags before publishing", len(flags))
+	}
+	filepath.WalkDir(challengePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path == filepath.Join(challengePath, "challenge.json") {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > lintMaxFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		content := strings.ToLower(string(data))
+		for _, f := range flags {
+			if !strings.Contains(content, strings.ToLower(f.value)) {
+				continue
+			}
+			if f.n == 0 {
+				v.warn(path, "", "contains the flag in plain text and is readable by players")
+			} else {
+				v.warn(path, "", "contains flag #%d in plain text and is readable by players", f.n)
+			}
+		}
+		return nil
+	})
+}
+
+// sortedIntKeys returns the keys of an int-keyed map in sorted order.
+func sortedIntKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// runLint implements the lint command. It prints every finding and returns
+// the process exit code, which is non-zero only when errors were found.
+func runLint(root string) int {
+	fmt.Printf("Analisando desafios em %s...\n", root)
+	problems := lintRoot(root)
+	errs := 0
+	for _, p := range problems {
+		fmt.Println(p)
+		if !p.Warning {
+			errs++
+		}
+	}
+	fmt.Printf("\n%d erro(s), %d aviso(s).\n", errs, len(problems)-errs)
+	if errs > 0 {
+		return 1
+	}
+	return 0
+}
@@ -2,26 +2,23 @@ package main
 
 import (
 	"archive/tar"
-	"bufio"
 	"bytes"
 	"context"
-	"encoding/json"
-	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
-	"syscall"
-	"os/exec"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 )
@@ -30,89 +27,50 @@ import (
 // that defines the order of challenges.
 type Config struct {
 	Challenges []string `json:"challenges"`
+
+	// Lifetime bounds how long challenge instances run, unless a challenge
+	// sets its own.
+	Lifetime LifetimePolicy `json:"lifetime,omitzero"`
+
+	// Root is the directory config.json was loaded from.
+	Root string `json:"-"`
 }
 
 // Challenge represents the metadata for a single challenge (challenge.json).
 type Challenge struct {
     Name     string   `json:"name"`
-    Flag     string   `json:"flag"`     // Single flag (backward compatible)
-    Flags    []string `json:"flags"`    // Multiple flags (new)
+    Flag     FlagSpec   `json:"flag"`  // Single flag (backward compatible)
+    Flags    []FlagSpec `json:"flags"` // Multiple flags (new)
     Hints    []string `json:"hints"`
     Ports    []int    `json:"ports"`    // Mude para Ports e tipo []int
     Preface  string   `json:"preface"`
     Postface string   `json:"postface"`
-}
-
-
-func main() {
-	// Define and parse the --build command-line flag.
-	build := flag.Bool("build", false, "Force rebuild of all challenge images")
-	clean := flag.Bool("clean", false, "Remove all challenge images and containers")
-	debug := flag.Bool("debug", false, "Show verbose output including Docker operations")
-	flag.Parse()
-
-	// Set up signal handler to catch Ctrl+C
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-	// Launch goroutine to handle interrupt signals
-	go func() {
-		for range sigChan {
-			fmt.Println("\n⚠️  Ctrl+C desabilitado. Por favor, digite 'quit' ou 'exit' para encerrar corretamente.")
-		}
-	}()
-
-	// Create a new Docker client.
-	ctx := context.Background()
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		log.Fatalf("Error: Could not create Docker client. Is Docker running? Details: %v", err)
-	}
 
-	// Check if Docker is running by pinging the daemon.
-	_, err = cli.Ping(ctx)
-	if err != nil {
-		log.Fatalf("Error: Could not connect to Docker daemon. Please make sure Docker is running. Details: %v", err)
-	}
+    // Chaining: keep the environment up after a solve and/or jump to a
+    // specific challenge instead of the next one in config.json.
+    KeepRunningAfterSolve bool   `json:"keep_running_after_solve"`
+    Next                  string `json:"next"`
 
-	// Handle --clean flag
-	if *clean {
-		fmt.Println("Limpando todas as imagens e containers dos desafios...")
-		cleanAll(ctx, cli)
-		fmt.Println("Todos os recursos dos desafios foram removidos.")
-		return
-	}
+    // DynamicFlag, when set, replaces Flag with one generated per session.
+    DynamicFlag *DynamicFlag `json:"dynamic_flag"`
 
-	fmt.Println("###########################################")
-	fmt.Println("## Bem-vindo à Plataforma de Desafios WSS ##")
-	fmt.Println("###########################################")
+    // Submissions tunes throttling and feedback for wrong flags.
+    Submissions SubmissionPolicy `json:"submissions"`
 
-	// Load the main configuration file.
-	configFile, err := os.ReadFile("/wss-ctf/challenges/config.json")
-	if err != nil {
-		log.Fatalf("Error: Could not read /wss-ctf/challenges/config.json. Details: %v", err)
-	}
+    // Scoring sets the points of the challenge and what reduces them.
+    Scoring ScoringRules `json:"scoring"`
 
-	var config Config
-	if err := json.Unmarshal(configFile, &config); err != nil {
-		log.Fatalf("Error: Could not parse /wss-ctf/challenges/config.json. Details: %v", err)
-	}
+    // TimeLimit, when set, ends the session once this much time was spent
+    // on the challenge.
+    TimeLimit Duration `json:"time_limit"`
 
-	// Start with first challenge directly
-	// Run first challenge (01-first-chal)
-	if len(config.Challenges) > 0 {
-		firstChallenge := config.Challenges[0]
-		result := runChallenge(ctx, cli, firstChallenge, *build, *debug, false)
+    // Lifetime overrides the instance TTL and idle timeout of config.json.
+    Lifetime LifetimePolicy `json:"lifetime,omitzero"`
+}
 
-		// After first challenge, check if we should continue to second
-		if result == "continue" && len(config.Challenges) > 1 {
-			// Start second challenge silently
-			secondChallenge := config.Challenges[1]
-			runChallenge(ctx, cli, secondChallenge, *build, *debug, true)
-		}
-	}
 
-	fmt.Println("\nSessão de desafios encerrada. Até logo!")
+func main() {
+	os.Exit(runCLI(os.Args[1:]))
 }
 
 func fileExists(filename string) bool {
@@ -123,356 +81,127 @@ func fileExists(filename string) bool {
     return !info.IsDir()
 }
 
-// runChallenge acts as a router, detecting the challenge type and calling the appropriate handler.
-func runChallenge(ctx context.Context, cli *client.Client, dirName string, forceBuild bool, debug bool, silent bool) string {
-    challengePath := filepath.Join("/wss-ctf/challenges", dirName)
-    composePath := filepath.Join(challengePath, "docker-compose.yml")
-    dockerfilePath := filepath.Join(challengePath, "Dockerfile")
-
-    if fileExists(composePath) {
-        // This is a Docker Compose-based challenge
-        return runComposeChallenge(ctx, cli, challengePath, debug, silent)
-    } else if fileExists(dockerfilePath) {
-        // This is a Dockerfile-based challenge
-        return runDockerfileChallenge(ctx, cli, dirName, forceBuild, debug, silent)
-    } else {
-        log.Printf("Error: No Dockerfile or docker-compose.yml found for challenge '%s'", dirName)
-        return "menu"
-    }
+// runChallenge acts as a router, detecting the challenge type and running it
+// on top of the matching runtime. It returns the finished session, whose
+// last transition tells the caller where the player goes next.
+//
+// Progress is recorded in store, and an unsolved attempt found there is
+// resumed.
+func runChallenge(ctx context.Context, cli *client.Client, config Config, store *progressStore, dirName string, forceBuild bool, debug bool, silent bool) *Session {
+	sess, err := startChallenge(ctx, cli, config, store, dirName, forceBuild, debug, silent)
+	if err != nil {
+		return sess
+	}
+	outcome := interact(ctx, sess.inst)
+	endChallenge(ctx, sess, outcome)
+	return sess
 }
 
-// runComposeChallenge handles challenges defined by a docker-compose.yml file.
-func runComposeChallenge(ctx context.Context, cli *client.Client, challengePath string, debug bool, silent bool) string {
-    // Load challenge metadata, which is common for all challenge types
-    challengeFile, err := os.ReadFile(filepath.Join(challengePath, "challenge.json"))
-    if err != nil {
-        log.Printf("Error: Could not read challenge.json in %s. Details: %v", challengePath, err)
-        return "menu"
-    }
-    var challenge Challenge
-    if err := json.Unmarshal(challengeFile, &challenge); err != nil {
-        log.Printf("Error: Could not parse challenge.json in %s. Details: %v", challengePath, err)
-        return "menu"
-    }
-
-    if !silent {
-        fmt.Printf("\n--- Iniciando Desafio: %s ---\n", challenge.Name)
-        fmt.Println("docker-compose.yml detectado, iniciando ambiente...")
-    }
-
-    // --- Docker Compose Logic ---
-    // We execute docker-compose as an external command
-    cmdUp := exec.Command("docker", "compose", "up", "-d")
-    cmdUp.Dir = challengePath // Run the command in the challenge's directory
-
-    // Capture stderr to show errors even in silent mode
-    var stderr bytes.Buffer
-    cmdUp.Stderr = &stderr
-
-    if debug && !silent {
-        cmdUp.Stdout = os.Stdout
-    }
-    if err := cmdUp.Run(); err != nil {
-        log.Printf("Error starting docker-compose for challenge '%s': %v\nOutput: %s", challenge.Name, err, stderr.String())
-        // Attempt to clean up even if startup failed
-        cmdDown := exec.Command("docker", "compose", "down", "-v")
-        cmdDown.Dir = challengePath
-        cmdDown.Run()
-        return "menu"
-    }
-    // --- End Docker Compose Logic ---
-
-    if !silent {
-        fmt.Printf("\n✅ Desafio '%s' está rodando!\n", challenge.Name)
-        fmt.Println("   Você pode interagir com ele em:")
-        for _, port := range challenge.Ports {
-            // Adiciona uma descrição simples para as portas conhecidas
-            if port == 9001 {
-                fmt.Printf("   - Console Web: http://127.0.0.1:%d\n", port)
-            } else if port == 9000 {
-                fmt.Printf("   - API Endpoint: http://127.0.0.1:%d\n", port)
-            } else {
-                fmt.Printf("   - http://127.0.0.1:%d\n", port)
-            }
-        }
-        fmt.Println()
-
-        if challenge.Preface != "" {
-            fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-            fmt.Println(challenge.Preface)
-            fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-        }
-    }
+// startChallenge prepares and starts a challenge. The session it returns is
+// Running, or Failed when the challenge could not be started, in which case
+// the error says why.
+func startChallenge(ctx context.Context, cli *client.Client, config Config, store *progressStore, dirName string, forceBuild bool, debug bool, silent bool) (*Session, error) {
+	inst, err := newInstance(cli, config, dirName)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return failSession(ctx, nil, err), err
+	}
+	inst.ForceBuild, inst.Debug, inst.Silent = forceBuild, debug, silent
+	inst.store = store
+	inst.SessionID = newSessionID()
+	inst.ttl, inst.idleTimeout = config.lifetime(inst.Challenge)
+	if inst.ttl > 0 {
+		inst.expires = time.Now().Add(inst.ttl)
+	}
+	inst.holdLease()
+	sess := newSession(inst)
+	if err := inst.generateFlag(); err != nil {
+		log.Printf("Error: Failed to generate flag for challenge %s. Details: %v", dirName, err)
+		return failSession(ctx, sess, err), err
+	}
+	challenge, rt := inst.Challenge, inst.Runtime
 
-    // The user interaction loop
-    reader := bufio.NewReader(os.Stdin)
-    hintIndex := 0
-    var finalResult string
-
-    // Multi-flag support
-    foundFlags := make(map[string]bool)
-    hasMultipleFlags := len(challenge.Flags) > 0
-    totalFlags := len(challenge.Flags)
-
-    interactionLoop:
-    for {
-        fmt.Print("Digite a flag > ")
-        input, _ := reader.ReadString('\n')
-        input = strings.TrimSpace(input)
-
-        // Check for special commands first
-        switch strings.ToLower(input) {
-        case "hint":
-             if len(challenge.Hints) == 0 {
-                fmt.Println("Nenhuma dica disponível para este desafio.")
-            } else if hintIndex < len(challenge.Hints) {
-                fmt.Printf("Dica %d/%d: %s\n", hintIndex+1, len(challenge.Hints), challenge.Hints[hintIndex])
-                hintIndex++
-            } else {
-                fmt.Println("Não há mais dicas disponíveis.")
-            }
-            continue
-        case "quit", "exit":
-            finalResult = "quit"
-            break interactionLoop
-        }
-
-        // Flag validation
-        if hasMultipleFlags {
-            // Multi-flag mode
-            flagFound := false
-            for _, validFlag := range challenge.Flags {
-                if strings.EqualFold(input, validFlag) {
-                    if foundFlags[validFlag] {
-                        fmt.Println("Flag já encontrada")
-                    } else {
-                        foundFlags[validFlag] = true
-                        fmt.Println("\n✅ Correto! Flag encontrada.")
-
-                        // Check if all flags found
-                        if len(foundFlags) == totalFlags {
-                            if challenge.Postface != "" {
-                                fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-                                fmt.Println(challenge.Postface)
-                                fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-                            }
-                            finalResult = "complete"
-                            break interactionLoop
-                        }
-                    }
-                    flagFound = true
-                    break
-                }
-            }
-            if !flagFound {
-                fmt.Println("Flag incorreta. Tente novamente. (Digite 'hint' para uma dica, ou 'quit' para sair)")
-            }
-        } else {
-            // Single flag mode (backward compatible)
-            if strings.EqualFold(input, challenge.Flag) {
-                fmt.Println("\n✅ Correto! Muito bem.")
-                if challenge.Postface != "" {
-                    fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-                    fmt.Println(challenge.Postface)
-                    fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-                }
-                fmt.Println("\nDigite 'next' para ir direto ao próximo desafio, ou pressione Enter para voltar ao menu...")
-                inputNext, _ := reader.ReadString('\n')
-                if strings.EqualFold(strings.TrimSpace(inputNext), "next") {
-                    finalResult = "next"
-                } else {
-                    finalResult = "menu"
-                }
-                break interactionLoop
-            } else {
-                fmt.Println("Flag incorreta. Tente novamente. (Digite 'hint' para uma dica, ou 'quit' para sair)")
-            }
-        }
-    }
+	if !silent {
+		fmt.Printf("\n--- Iniciando Desafio: %s ---\n", challenge.Name)
+	}
+	if err := rt.Prepare(ctx, inst); err != nil {
+		log.Printf("Error: Failed to prepare challenge %s. Details: %v", dirName, err)
+		return failSession(ctx, sess, err), err
+	}
+	if err := rt.Start(ctx, inst); err != nil {
+		log.Printf("Error: Failed to start challenge %s. Details: %v", dirName, err)
+		return failSession(ctx, sess, err), err
+	}
+	if _, err := sess.advance(ctx, OutcomeStarted); err != nil {
+		log.Printf("Error: %v", err)
+	}
+	inst.event(Event{Type: eventChallengeStart})
 
-    // Cleanup for Docker Compose
-    fmt.Println("\nEncerrando o ambiente do desafio atual...")
-    cmdDown := exec.Command("docker", "compose", "down", "-v")
-    cmdDown.Dir = challengePath
-    if err := cmdDown.Run(); err != nil {
-        log.Printf("Warning: could not run 'docker compose down': %v", err)
-    }
-    
-    return finalResult
+	if !silent {
+		fmt.Printf("\n✅ Desafio '%s' está rodando!\n", challenge.Name)
+		printEndpoints(rt.Endpoints(inst))
+		if challenge.Preface != "" {
+			printBanner(challenge.Preface)
+		}
+	}
+	return sess, nil
 }
 
-// runDockerfileChallenge handles challenges defined by a Dockerfile.
-func runDockerfileChallenge(ctx context.Context, cli *client.Client, dirName string, forceBuild bool, debug bool, silent bool) string {
-    // This function contains the exact same logic as your original runChallenge function
-    challengePath := filepath.Join("/wss-ctf/challenges", dirName)
-    challengeFile, err := os.ReadFile(filepath.Join(challengePath, "challenge.json"))
-    if err != nil {
-        log.Printf("Error: Could not read challenge.json in %s. Skipping. Details: %v", challengePath, err)
-        return "menu"
-    }
-    var challenge Challenge
-    if err := json.Unmarshal(challengeFile, &challenge); err != nil {
-        log.Printf("Error: Could not parse challenge.json in %s. Skipping. Details: %v", challengePath, err)
-        return "menu"
-    }
-
-    if !silent {
-        fmt.Printf("\n--- Iniciando Desafio: %s ---\n", challenge.Name)
-    }
+// endChallenge moves a running session along on the way the player left
+// it, then records the score and journals the end of the session.
+func endChallenge(ctx context.Context, sess *Session, outcome Outcome) {
+	inst := sess.inst
+	if _, err := sess.advance(ctx, outcome); err != nil {
+		// Should not happen; make sure nothing is left running.
+		log.Printf("Error: %v", err)
+		sess.advance(ctx, OutcomeQuit)
+	}
+	if err := recordScore(inst, sess.State, time.Now()); err != nil {
+		log.Printf("Warning: could not record score: %v", err)
+	}
+	stop := Event{Type: eventChallengeStop, Result: strings.ToLower(sess.State.String())}
+	if inst.Score.Enabled() {
+		total := inst.Score.Total()
+		stop.Score = &total
+	}
+	inst.event(stop)
+}
 
-    imageTag := "challenge-" + strings.ToLower(dirName) + ":latest"
-    containerName := "challenge-container-" + strings.ToLower(dirName)
-    cleanup(ctx, cli, containerName, "", false, debug)
-    exists, err := imageExists(ctx, cli, imageTag)
-    if err != nil {
-        log.Printf("Warning: Could not check if image '%s' exists: %v. Attempting to build.", imageTag, err)
-    }
-    if forceBuild || !exists {
-        if forceBuild && debug && !silent {
-            fmt.Print("Build forced by user with --build flag")
-        }
-        err = buildImage(ctx, cli, challengePath, imageTag, debug && !silent)
-        if err != nil {
-            log.Printf("Error: Failed to build Docker image for challenge %s. Details: %v", dirName, err)
-            return "fail"
-        }
-    } else {
-        if debug && !silent {
-            fmt.Printf("Using existing image '%s'. Use --build to force a rebuild.\n", imageTag)
-        }
-    }
-    if len(challenge.Ports) == 0 {
-    log.Printf("Error: No ports defined in challenge.json for '%s'", challenge.Name)
-    return "fail"
-    }
-    _, err = runContainer(ctx, cli, imageTag, containerName, challenge.Ports[0], debug && !silent)
-    if err != nil {
-        log.Printf("Error: Failed to run Docker container for challenge %s. Details: %v", dirName, err)
-        cleanup(ctx, cli, containerName, imageTag, true, debug)
-        return "fail"
-    }
+// newInstance detects the runtime of a listed challenge and loads its
+// metadata.
+func newInstance(cli *client.Client, config Config, dirName string) (*ChallengeInstance, error) {
+	challengePath := config.ChallengePath(dirName)
+	rt := detectRuntime(challengePath)
+	if rt == nil {
+		return nil, fmt.Errorf("no Dockerfile or docker-compose.yml found for challenge '%s'", dirName)
+	}
 
-    if !silent {
-        fmt.Printf("\n✅ Desafio '%s' está rodando!\n", challenge.Name)
-        fmt.Println("   Você pode interagir com ele em:")
-        for _, port := range challenge.Ports {
-            // Adiciona uma descrição simples para as portas conhecidas
-            if port == 9001 {
-                fmt.Printf("   - Console Web: http://127.0.0.1:%d\n", port)
-            } else if port == 9000 {
-                fmt.Printf("   - API Endpoint: http://127.0.0.1:%d\n", port)
-            } else {
-                fmt.Printf("   - http://127.0.0.1:%d\n", port)
-            }
-        }
-        if challenge.Preface != "" {
-            fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-            fmt.Println(challenge.Preface)
-            fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-        }
-    }
-    reader := bufio.NewReader(os.Stdin)
-    hintIndex := 0
-    var finalResult string
-
-    // Check if this is the first challenge (01-first-chal)
-    isFirstChallenge := strings.Contains(dirName, "01-first-chal")
-
-    // Multi-flag support
-    foundFlags := make(map[string]bool)
-    hasMultipleFlags := len(challenge.Flags) > 0
-    totalFlags := len(challenge.Flags)
-
-    interactionLoop:
-    for {
-        fmt.Print("Digite a flag > ")
-        input, _ := reader.ReadString('\n')
-        input = strings.TrimSpace(input)
-
-        // Check for special commands first
-        switch strings.ToLower(input) {
-        case "hint":
-            if len(challenge.Hints) == 0 {
-                fmt.Println("Nenhuma dica disponível para este desafio.")
-            } else if hintIndex < len(challenge.Hints) {
-                fmt.Printf("Dica %d/%d: %s\n", hintIndex+1, len(challenge.Hints), challenge.Hints[hintIndex])
-                hintIndex++
-            } else {
-                fmt.Println("Não há mais dicas disponíveis.")
-            }
-            continue
-        case "quit", "exit":
-            finalResult = "quit"
-            break interactionLoop
-        }
-
-        // Flag validation
-        if hasMultipleFlags {
-            // Multi-flag mode
-            flagFound := false
-            for _, validFlag := range challenge.Flags {
-                if strings.EqualFold(input, validFlag) {
-                    if foundFlags[validFlag] {
-                        fmt.Println("Flag já encontrada")
-                    } else {
-                        foundFlags[validFlag] = true
-                        fmt.Println("\n✅ Correto! Flag encontrada.")
-
-                        // Check if all flags found
-                        if len(foundFlags) == totalFlags {
-                            if challenge.Postface != "" {
-                                fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-                                fmt.Println(challenge.Postface)
-                                fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-                            }
-                            finalResult = "complete"
-                            break interactionLoop
-                        }
-                    }
-                    flagFound = true
-                    break
-                }
-            }
-            if !flagFound {
-                fmt.Println("Flag incorreta. Tente novamente. (Digite 'hint' para uma dica, ou 'quit' para sair)")
-            }
-        } else {
-            // Single flag mode (backward compatible)
-            if strings.EqualFold(input, challenge.Flag) {
-                fmt.Println("\n✅ Correto! Muito bem.")
-                if challenge.Postface != "" {
-                    fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-                    fmt.Println(challenge.Postface)
-                    fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-                }
-
-                if isFirstChallenge {
-                    // For first challenge, don't break - return "continue" to keep it running
-                    finalResult = "continue"
-                    break interactionLoop
-                } else {
-                    // For other challenges, ask if they want to continue
-                    fmt.Println("\nDigite 'next' para ir direto ao próximo desafio, ou pressione Enter para voltar ao menu...")
-                    inputNext, _ := reader.ReadString('\n')
-                    if strings.EqualFold(strings.TrimSpace(inputNext), "next") {
-                        finalResult = "next"
-                    } else {
-                        finalResult = "menu"
-                    }
-                    break interactionLoop
-                }
-            } else {
-                fmt.Println("Flag incorreta. Tente novamente. (Digite 'hint' para uma dica, ou 'quit' para sair)")
-            }
-        }
-    }
+	// Load challenge metadata, which is common for all challenge types
+	challenge, err := loadChallenge(challengePath)
+	if err != nil {
+		return nil, err
+	}
+	return &ChallengeInstance{
+		DirName:   dirName,
+		Path:      challengePath,
+		Challenge: challenge,
+		Runtime:   rt,
+		cli:       cli,
+	}, nil
+}
 
-    // Don't cleanup first challenge if returning "continue"
-    if !(isFirstChallenge && finalResult == "continue") {
-        fmt.Println("\nEncerrando o desafio atual...")
-        cleanup(ctx, cli, containerName, imageTag, false, debug)
-    }
-    return finalResult
+// failSession moves a session that could not start to the Failed state,
+// cleaning up whatever it managed to create, and journals why. sess may be
+// nil when the challenge could not even be loaded.
+func failSession(ctx context.Context, sess *Session, cause error) *Session {
+	if sess == nil {
+		sess = newSession(nil)
+	}
+	sess.advance(ctx, OutcomeFail)
+	if sess.inst != nil {
+		sess.inst.event(Event{Type: eventChallengeStop, Result: strings.ToLower(sess.State.String()), Error: cause.Error()})
+	}
+	return sess
 }
 
 // Checks if a Docker image with the given tag exists locally.
@@ -490,8 +219,9 @@ func imageExists(ctx context.Context, cli *client.Client, imageTag string) (bool
 	return len(images) > 0, nil
 }
 
-// buildImage creates a Docker image from a Dockerfile in the given path.
-func buildImage(ctx context.Context, cli *client.Client, buildContextPath, tag string, debug bool) error {
+// buildImage creates a Docker image from a Dockerfile in the given path,
+// passing buildArgs (which may be nil) to the build.
+func buildImage(ctx context.Context, cli *client.Client, buildContextPath, dockerfile, tag string, labels map[string]string, buildArgs map[string]*string, debug bool) error {
 	if debug {
 		fmt.Printf("Building image '%s'...\n", tag)
 	}
@@ -533,7 +263,9 @@ func buildImage(ctx context.Context, cli *client.Client, buildContextPath, tag s
 	buildOptions := types.ImageBuildOptions{
 		Tags:       []string{tag},
 		Remove:     true, // Remove intermediate containers after a successful build
-		Dockerfile: "Dockerfile",
+		Dockerfile: dockerfile,
+		Labels:     labels,
+		BuildArgs:  buildArgs,
 	}
 
 	// Call the Docker SDK to build the image.
@@ -555,15 +287,20 @@ func buildImage(ctx context.Context, cli *client.Client, buildContextPath, tag s
 	return nil
 }
 
-// runContainer creates and starts a container from a given image.
-func runContainer(ctx context.Context, cli *client.Client, image, name string, hostPort int, debug bool) (string, error) {
+// challengeContainerPort is the port Dockerfile challenges listen on inside
+// their container; the challenge's first host port is mapped to it.
+const challengeContainerPort = 80
+
+// runContainer creates and starts a container from a given image, with
+// extra environment entries and mounts.
+func runContainer(ctx context.Context, cli *client.Client, image, name string, hostPort int, env []string, mounts []mount.Mount, labels map[string]string, debug bool) (string, error) {
 	if debug {
 		fmt.Printf("Starting container '%s' from image '%s'...\n", name, image)
 	}
 
 	// Configure port mapping.
 	portStr := strconv.Itoa(hostPort)
-	exposedPorts, portBindings, err := nat.ParsePortSpecs([]string{fmt.Sprintf("%s:80", portStr)})
+	exposedPorts, portBindings, err := nat.ParsePortSpecs([]string{fmt.Sprintf("%s:%d", portStr, challengeContainerPort)})
 	if err != nil {
 		return "", fmt.Errorf("failed to parse port specs: %w", err)
 	}
@@ -572,8 +309,11 @@ func runContainer(ctx context.Context, cli *client.Client, image, name string, h
 	resp, err := cli.ContainerCreate(ctx, &container.Config{
 		Image:        image,
 		ExposedPorts: exposedPorts,
+		Env:          env,
+		Labels:       labels,
 	}, &container.HostConfig{
 		PortBindings: portBindings,
+		Mounts:       mounts,
 	}, nil, nil, name)
 	if err != nil {
 		return "", fmt.Errorf("failed to create container: %w", err)
@@ -619,40 +359,54 @@ func cleanup(ctx context.Context, cli *client.Client, containerName, imageName s
 	}
 }
 
-// cleanAll removes all challenge containers and images
-func cleanAll(ctx context.Context, cli *client.Client) {
-	// Load the configuration to get all challenge directories
-	configFile, err := os.ReadFile("/wss-ctf/challenges/config.json")
-	if err != nil {
-		log.Printf("Warning: Could not read /wss-ctf/challenges/config.json: %v", err)
-		return
-	}
-
-	var config Config
-	if err := json.Unmarshal(configFile, &config); err != nil {
-		log.Printf("Warning: Could not parse /wss-ctf/challenges/config.json: %v", err)
-		return
+// cleanAll removes the containers, networks, volumes and images the
+// platform created for the given challenges, found by their labels, whether
+// or not config.json still lists them. With no challenges given, it removes
+// those of every challenge. The image and container that challenges listed
+// in config.json got by name, before resources were labelled, go too.
+func cleanAll(ctx context.Context, cli *client.Client, config Config, challenges []string, debug bool) (removedResources, error) {
+	filterSets := []filters.Args{filters.NewArgs(filters.Arg("label", labelChallenge))}
+	named := config.Challenges
+	if len(challenges) > 0 {
+		filterSets = filterSets[:0]
+		named = nil
+		for _, dir := range challenges {
+			filterSets = append(filterSets, labelFilter(map[string]string{labelChallenge: dir}))
+			if slices.Contains(config.Challenges, dir) {
+				named = append(named, dir)
+			}
+		}
 	}
 
-	// Remove all challenge containers and images
-	for _, challengeDir := range config.Challenges {
-		imageTag := "challenge-" + strings.ToLower(challengeDir) + ":latest"
-		containerName := "challenge-container-" + strings.ToLower(challengeDir)
-
-		// Stop and remove container if it exists
-		timeout := 3
-		if err := cli.ContainerStop(ctx, containerName, container.StopOptions{Timeout: &timeout}); err != nil {
-			// Container might not exist, continue
+	var total removedResources
+	for _, args := range filterSets {
+		// Forget the leases of the instances about to go.
+		sessions := args.Clone()
+		sessions.Add("label", labelSession)
+		if instances, err := labeledInstances(ctx, cli, sessions); err == nil {
+			for session := range instances {
+				removeLease(session)
+			}
 		}
-		if err := cli.ContainerRemove(ctx, containerName, container.RemoveOptions{Force: true}); err != nil {
-			// Container might not exist, continue
+
+		removed, err := removeLabeledResources(ctx, cli, args, true, debug)
+		total.Containers += removed.Containers
+		total.Networks += removed.Networks
+		total.Volumes += removed.Volumes
+		total.Images += removed.Images
+		if err != nil {
+			return total, err
 		}
+	}
 
-		// Remove image
-		if _, err := cli.ImageRemove(ctx, imageTag, image.RemoveOptions{Force: true}); err != nil {
-			log.Printf("Note: Could not remove image %s (might not exist)\n", imageTag)
-		} else {
-			fmt.Printf("Removed image: %s\n", imageTag)
+	for _, dir := range named {
+		name := strings.ToLower(dir)
+		if err := removeContainer(ctx, cli, "challenge-container-"+name, debug); err == nil {
+			total.Containers++
+		}
+		if _, err := cli.ImageRemove(ctx, "challenge-"+name+":latest", image.RemoveOptions{Force: true}); err == nil {
+			total.Images++
 		}
 	}
+	return total, nil
 }
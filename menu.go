@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// runMenu shows the main menu and runs the chosen challenges until the
+// player quits.
+func runMenu(ctx context.Context, cli *client.Client, config Config, forceBuild bool, debug bool) {
+	store := openProgress(activePlayer)
+	defer func() {
+		if total, scored := store.totalScore(); scored {
+			fmt.Printf("\nPontuação final: %d pontos.\n", total)
+		}
+	}()
+
+	for {
+		printMenu(config, store)
+		fmt.Print("Escolha um desafio > ")
+		input, err := readLine()
+		if err != nil {
+			// stdin was closed: nobody is left to choose.
+			fmt.Println()
+			recordEvent(activePlayer, Event{Type: eventQuit})
+			return
+		}
+
+		switch strings.ToLower(input) {
+		case "quit", "exit":
+			recordEvent(activePlayer, Event{Type: eventQuit})
+			return
+		case "":
+			continue
+		}
+
+		choice, err := strconv.Atoi(input)
+		if err != nil || choice < 1 || choice > len(config.Challenges) {
+			fmt.Println("Opção inválida. Digite o número de um desafio, ou 'quit' para sair.")
+			continue
+		}
+
+		if quit := playFrom(ctx, cli, config, choice-1, store, forceBuild, debug); quit {
+			return
+		}
+	}
+}
+
+// playFrom runs the challenge at index and keeps going while the player asks
+// for the next one. Environments kept alive along the chain are torn down
+// when it ends. It reports whether the player chose to quit.
+func playFrom(ctx context.Context, cli *client.Client, config Config, index int, store *progressStore, forceBuild bool, debug bool) bool {
+	var keptAlive []*Session
+	defer func() {
+		for _, sess := range keptAlive {
+			if _, err := sess.advance(ctx, OutcomeRelease); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}()
+
+	dirName := config.Challenges[index]
+	silent := false
+	for {
+		sess := runChallenge(ctx, cli, config, store, dirName, forceBuild, debug, silent)
+		store.finish(dirName, sess, time.Now())
+		if sess.State == StateKeptAlive {
+			keptAlive = append(keptAlive, sess)
+		}
+
+		switch sess.Last.Nav {
+		case NavQuit:
+			return true
+		case NavNext:
+			silent = false
+		case NavNextSilent:
+			// The solved challenge is still running; start the next one
+			// without the usual introduction.
+			silent = true
+		default:
+			return false
+		}
+
+		next, ok := nextChallenge(config, dirName, sess)
+		if !ok {
+			fmt.Println("\nVocê chegou ao último desafio!")
+			return false
+		}
+		dirName = next
+	}
+}
+
+// nextChallenge picks the challenge that follows dirName: the one named by
+// its "next" field if set, otherwise the following entry in config.json.
+func nextChallenge(config Config, dirName string, sess *Session) (string, bool) {
+	if sess.inst != nil && sess.inst.Challenge.Next != "" {
+		if !slices.Contains(config.Challenges, sess.inst.Challenge.Next) {
+			log.Printf("Warning: challenge '%s' chains into unknown challenge '%s'", dirName, sess.inst.Challenge.Next)
+			return "", false
+		}
+		return sess.inst.Challenge.Next, true
+	}
+	i := slices.Index(config.Challenges, dirName)
+	if i < 0 || i+1 >= len(config.Challenges) {
+		return "", false
+	}
+	return config.Challenges[i+1], true
+}
+
+// printMenu lists the configured challenges with their solved status, the
+// points scored in them and how far unsolved attempts got.
+func printMenu(config Config, store *progressStore) {
+	fmt.Println("\n=========== Menu Principal ===========")
+	fmt.Printf("  Jogador: %s\n", activePlayer)
+	for i, dirName := range config.Challenges {
+		name := dirName
+		if challenge, err := loadChallenge(config.ChallengePath(dirName)); err == nil && challenge.Name != "" {
+			name = challenge.Name
+		}
+		status, details := "[ ]", ""
+		if p := store.Challenges[dirName]; p != nil {
+			if p.Solved {
+				status = "[✔]"
+			}
+			if p.BestScore != nil {
+				details += fmt.Sprintf(" (%d pts)", *p.BestScore)
+			}
+			if p.Attempt != nil && len(p.Attempt.Found) > 0 {
+				details += fmt.Sprintf(" — em andamento: %d flag(s)", len(p.Attempt.Found))
+			}
+		}
+		fmt.Printf("  %d. %s %s%s\n", i+1, status, name, details)
+	}
+	if total, scored := store.totalScore(); scored {
+		fmt.Printf("  Pontuação total: %d\n", total)
+	}
+	fmt.Println("======================================")
+	fmt.Println("Digite o número do desafio, ou 'quit' para sair.")
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPIDocument describes the REST API in OpenAPI 3.1. It is generated
+// from apiRoutes and the Go types of the request and response bodies; field
+// descriptions come from their doc struct tags.
+func openAPIDocument() map[string]any {
+	g := &schemaGen{defs: make(map[string]any)}
+	paths := make(map[string]any)
+	for _, route := range apiRoutes() {
+		op := map[string]any{
+			"operationId": route.ID,
+			"summary":     route.Summary,
+		}
+		var params []any
+		for _, m := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+			params = append(params, map[string]any{
+				"name":     m[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+		if route.Player {
+			params = append(params, map[string]any{"$ref": "#/components/parameters/player"})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		if route.Request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent(g.schema(reflect.TypeOf(route.Request))),
+			}
+		}
+		responses := make(map[string]any)
+		for _, resp := range route.Responses {
+			r := map[string]any{"description": resp.Description}
+			if resp.Body != nil {
+				r["content"] = jsonContent(g.schema(reflect.TypeOf(resp.Body)))
+			}
+			responses[strconv.Itoa(resp.Status)] = r
+		}
+		responses["401"] = map[string]any{"$ref": "#/components/responses/unauthorized"}
+		if route.Method != "GET" {
+			responses["403"] = map[string]any{"$ref": "#/components/responses/forbidden"}
+		}
+		op["responses"] = responses
+
+		item, _ := paths[route.Path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "WSS CTF API",
+			"version":     "1",
+			"description": "Start challenges, submit flags and reveal hints for a player. Every request needs the token serve printed, as a bearer token or in the cookie the web UI sets.",
+		},
+		"servers":  []any{map[string]any{"url": "/api"}},
+		"paths":    paths,
+		"security": []any{map[string]any{"bearer": []any{}}, map[string]any{"cookie": []any{}}},
+		"components": map[string]any{
+			"schemas": g.defs,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"cookie": map[string]any{"type": "apiKey", "in": "cookie", "name": tokenCookie},
+			},
+			"parameters": map[string]any{
+				"player": map[string]any{
+					"name":        "X-Player",
+					"in":          "header",
+					"description": "The player profile to act for. Defaults to the player cookie, then to the player serve was started for.",
+					"schema":      map[string]any{"type": "string", "pattern": playerNamePattern.String()},
+				},
+			},
+			"responses": map[string]any{
+				"unauthorized": map[string]any{
+					"description": "The token is missing or wrong.",
+					"content":     jsonContent(g.schema(reflect.TypeOf(apiError{}))),
+				},
+				"forbidden": map[string]any{
+					"description": "A request authenticated by the cookie came from another site.",
+					"content":     jsonContent(g.schema(reflect.TypeOf(apiError{}))),
+				},
+			},
+		},
+	}
+}
+
+// runOpenAPI implements the openapi command.
+func runOpenAPI() int {
+	data, err := json.MarshalIndent(openAPIDocument(), "", "  ")
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
+
+// pathParam matches the parameters of a route path, such as {slug}.
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// schemaGen builds JSON Schemas from Go types, collecting the structs it
+// meets as named components.
+type schemaGen struct {
+	defs map[string]any
+}
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[Duration]()
+)
+
+// schema returns the schema of t, as a reference for structs.
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "string", "description": "Go duration, e.g. 1m30s."}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "api")
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // reserved, in case t refers to itself
+			g.defs[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+// object returns the schema of a struct from its exported fields and their
+// json tags. Fields that are neither omitempty nor pointers are required.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		schema := g.schema(f.Type)
+		if doc := f.Tag.Get("doc"); doc != "" {
+			if _, isRef := schema["$ref"]; isRef {
+				schema = map[string]any{"allOf": []any{schema}}
+			}
+			schema["description"] = doc
+		}
+		properties[name] = schema
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+	obj := map[string]any{"type": "object", "properties": properties}
+	if required != nil {
+		obj["required"] = required
+	}
+	return obj
+}
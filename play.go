@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// play is a player working on a running challenge: the flags found, the
+// hints revealed, the score and the throttle on wrong flags. The stdin
+// prompt and the web UI both drive a play, so a flag is checked the same way
+// whichever the player uses.
+type play struct {
+	inst    *ChallengeInstance
+	att     *attempt
+	score   *Score
+	limiter *throttle
+	found   map[int]bool // keyed by position in flags()
+	limit   time.Duration
+}
+
+// newPlay starts playing a challenge, picking up where the player left an
+// unsolved attempt: time spent, hints and points carry over, along with the
+// flags already found.
+func newPlay(inst *ChallengeInstance, now time.Time) *play {
+	challenge := inst.Challenge
+	att := inst.resumeAttempt(now)
+	score := newScore(challenge, now.Add(-time.Duration(att.Elapsed)))
+	score.Earned, score.Hints = att.Earned, att.Hints
+	inst.Score = score
+
+	p := &play{
+		inst:    inst,
+		att:     att,
+		score:   score,
+		limiter: &throttle{policy: challenge.Submissions},
+		found:   make(map[int]bool),
+		limit:   time.Duration(challenge.TimeLimit),
+	}
+	for i := range challenge.Flags {
+		if slices.Contains(att.Found, flagKey(challenge.Flags, i)) {
+			p.found[i] = true
+		}
+	}
+	return p
+}
+
+// flags returns the flags that solve the challenge: its flags, or the
+// single flag of challenges that have only one.
+func (p *play) flags() []FlagSpec {
+	if len(p.inst.Challenge.Flags) > 0 {
+		return p.inst.Challenge.Flags
+	}
+	return []FlagSpec{p.inst.Challenge.Flag}
+}
+
+// multiFlag reports whether the challenge lists several flags.
+func (p *play) multiFlag() bool {
+	return len(p.inst.Challenge.Flags) > 0
+}
+
+// resumed reports whether the play picked up an earlier attempt.
+func (p *play) resumed() bool {
+	return len(p.found) > 0 || p.score.Hints > 0
+}
+
+// solved reports whether every flag was found.
+func (p *play) solved() bool {
+	return len(p.found) == len(p.flags())
+}
+
+// elapsed returns the time spent on the challenge, earlier attempts
+// included.
+func (p *play) elapsed(now time.Time) time.Duration {
+	return now.Sub(p.score.started)
+}
+
+// remaining returns the time left before the time limit runs out. It is
+// only meaningful when the challenge has a limit.
+func (p *play) remaining(now time.Time) time.Duration {
+	return max(p.limit-p.elapsed(now), 0)
+}
+
+// What can end a session before the player leaves it.
+const (
+	expiryLimit = "time_limit"
+	expiryTTL   = "ttl"
+	expiryIdle  = "idle"
+)
+
+// deadline returns when the session runs out of time if the player does
+// nothing after active, and why: the time limit, the instance's TTL or its
+// idle timeout. It returns the zero time when nothing bounds the session.
+func (p *play) deadline(active time.Time) (time.Time, string) {
+	var at time.Time
+	var why string
+	consider := func(t time.Time, reason string) {
+		if !t.IsZero() && (at.IsZero() || t.Before(at)) {
+			at, why = t, reason
+		}
+	}
+	if p.limit > 0 && !p.solved() {
+		consider(p.score.started.Add(p.limit), expiryLimit)
+	}
+	consider(p.inst.expires, expiryTTL)
+	if p.inst.idleTimeout > 0 {
+		consider(active.Add(p.inst.idleTimeout), expiryIdle)
+	}
+	return at, why
+}
+
+// hint reveals the next hint and the points it cost. ok is false when no
+// hints are left.
+func (p *play) hint(now time.Time) (text string, penalty int, ok bool) {
+	hints := p.inst.Challenge.Hints
+	n := p.score.Hints
+	if n >= len(hints) {
+		return "", 0, false
+	}
+	penalty = p.score.HintRevealed()
+	p.inst.saveAttempt(p.att, p.found, now)
+	p.inst.event(Event{Type: eventHint, Hint: n + 1})
+	return hints[n], penalty, true
+}
+
+// verdict is the result of a flag submission.
+type verdict struct {
+	// Result is flagCorrect, flagWrong, flagDuplicate, flagLocked or
+	// flagThrottled.
+	Result string
+	// Wait is how long a throttled player has to wait.
+	Wait time.Duration
+	// Feedback is what the challenge tells about a wrong flag, if anything.
+	Feedback string
+	// Index is the position of the flag found, and Points what it gave.
+	Index  int
+	Points int
+}
+
+// submit checks a flag and records the result.
+func (p *play) submit(ctx context.Context, input string, now time.Time) verdict {
+	if v, throttled := p.throttle(input, now); throttled {
+		return v
+	}
+	return p.apply(input, p.match(ctx, input, p.found), now)
+}
+
+// throttle turns a submission away while the player has to wait after too
+// many wrong flags, before any validator is run.
+func (p *play) throttle(input string, now time.Time) (verdict, bool) {
+	wait := p.limiter.wait(now)
+	if wait <= 0 {
+		return verdict{}, false
+	}
+	p.submitted(input, flagThrottled, "")
+	return verdict{Result: flagThrottled, Wait: wait}, true
+}
+
+// flagMatch is what a submission matched: the position of a flag not found
+// yet, or -1, and whether it matched a flag found before.
+type flagMatch struct {
+	index        int
+	alreadyFound bool
+}
+
+// match checks input against the flags, given those found so far. Flags not
+// found yet are tried first, so an answer matching several patterns counts
+// for a new objective. It changes nothing, so the web UI can run slow
+// validators without holding up other requests.
+func (p *play) match(ctx context.Context, input string, found map[int]bool) flagMatch {
+	flags := p.flags()
+	for i, f := range flags {
+		if !found[i] && p.inst.matchFlag(ctx, f, input) {
+			return flagMatch{index: i}
+		}
+	}
+	for i := range found {
+		if p.inst.matchFlag(ctx, flags[i], input) {
+			return flagMatch{index: -1, alreadyFound: true}
+		}
+	}
+	return flagMatch{index: -1}
+}
+
+// apply records a checked submission. A flag found by another submission
+// since it was checked counts as a duplicate.
+func (p *play) apply(input string, m flagMatch, now time.Time) verdict {
+	flags := p.flags()
+	match := m.index
+	switch {
+	case m.alreadyFound || match >= 0 && p.found[match]:
+		p.submitted(input, flagDuplicate, "")
+		return verdict{Result: flagDuplicate}
+	case match < 0:
+		p.submitted(input, flagWrong, "")
+		p.limiter.miss(now)
+		var remaining []FlagSpec
+		for i, f := range flags {
+			if !p.found[i] {
+				remaining = append(remaining, f)
+			}
+		}
+		return verdict{Result: flagWrong, Feedback: p.inst.Challenge.Submissions.feedback(input, remaining)}
+	case !flagUnlocked(flags, p.found, match):
+		p.submitted(input, flagLocked, p.key(match))
+		return verdict{Result: flagLocked, Index: match}
+	}
+
+	p.submitted(input, flagCorrect, p.key(match))
+	p.limiter.hit()
+	p.found[match] = true
+	points := p.score.FlagFound(match, now)
+	p.inst.saveAttempt(p.att, p.found, now)
+	return verdict{Result: flagCorrect, Index: match, Points: points}
+}
+
+// key identifies a flag in the journal. The flag of single-flag challenges
+// needs no key.
+func (p *play) key(i int) string {
+	if !p.multiFlag() {
+		return ""
+	}
+	return flagKey(p.inst.Challenge.Flags, i)
+}
+
+// submitted journals a flag submission and its result.
+func (p *play) submitted(input, result, flag string) {
+	p.inst.event(Event{Type: eventFlag, Input: inputHash(input), Result: result, Flag: flag})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultPlayer is the profile used when nobody says who is playing.
+const defaultPlayer = "default"
+
+// activePlayer is the profile whose progress, scores and journal are read
+// and written. It is chosen once at startup, by --player or the picker.
+var activePlayer = defaultPlayer
+
+// playerFiles are the files a profile keeps in its directory.
+var playerFiles = []string{"progress.json", "scores.jsonl", "journal.jsonl"}
+
+// playerNamePattern keeps profile names usable as directory names.
+var playerNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,31}$`)
+
+// checkPlayerName reports names that cannot be used for a profile.
+func checkPlayerName(name string) error {
+	if !playerNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid player name '%s': use up to 32 letters, digits, '.', '_' or '-'", name)
+	}
+	return nil
+}
+
+// playersDir returns the directory holding one directory per profile.
+func playersDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "players"), nil
+}
+
+// playerDir returns the directory of a profile.
+func playerDir(name string) (string, error) {
+	if err := checkPlayerName(name); err != nil {
+		return "", err
+	}
+	players, err := playersDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(players, name), nil
+}
+
+// playerPath returns where a profile keeps one of its files.
+func playerPath(player, name string) (string, error) {
+	dir, err := playerDir(player)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// createProfile makes sure a profile exists, so that it is listed even
+// before its player has achieved anything.
+func createProfile(name string) error {
+	dir, err := playerDir(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+// profile summarizes a player profile.
+type profile struct {
+	Name       string
+	Solved     int
+	Score      int
+	Scored     bool
+	LastActive time.Time
+}
+
+// listProfiles returns every profile, sorted by name.
+func listProfiles() ([]profile, error) {
+	players, err := playersDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(players)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var profiles []profile
+	for _, e := range entries {
+		if !e.IsDir() || checkPlayerName(e.Name()) != nil {
+			continue
+		}
+		p := profile{Name: e.Name()}
+		dir := filepath.Join(players, e.Name())
+		if store, err := loadProgress(filepath.Join(dir, "progress.json")); err == nil {
+			for _, c := range store.Challenges {
+				if c.Solved {
+					p.Solved++
+				}
+			}
+			p.Score, p.Scored = store.totalScore()
+		}
+		for _, name := range playerFiles {
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.ModTime().After(p.LastActive) {
+				p.LastActive = info.ModTime()
+			}
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// resetProfile removes the progress, scores and journal of a profile. The
+// profile itself is kept.
+func resetProfile(name string) error {
+	dir, err := playerDir(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("player '%s' does not exist", name)
+	}
+	for _, file := range playerFiles {
+		if err := os.Remove(filepath.Join(dir, file)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// pickPlayer asks who is playing, offering the existing profiles. A name
+// that is not listed creates a new profile; an empty answer, or a closed
+// stdin, picks the default one.
+func pickPlayer() string {
+	profiles, err := listProfiles()
+	if err != nil {
+		fmt.Printf("Não foi possível listar os jogadores: %v\n", err)
+	}
+	if len(profiles) > 0 {
+		fmt.Println("\nJogadores:")
+		for i, p := range profiles {
+			fmt.Printf("  %d. %s\n", i+1, p.Name)
+		}
+	}
+	for {
+		fmt.Printf("Quem está jogando? Digite o número ou o nome do jogador (Enter para '%s'): ", defaultPlayer)
+		input, err := readLine()
+		if input == "" || err != nil {
+			return defaultPlayer
+		}
+		if n, err := strconv.Atoi(input); err == nil {
+			if n >= 1 && n <= len(profiles) {
+				return profiles[n-1].Name
+			}
+			fmt.Println("Número de jogador inválido.")
+			continue
+		}
+		if err := checkPlayerName(input); err != nil {
+			fmt.Println("Nome inválido: use até 32 letras, números, '.', '_' ou '-'.")
+			continue
+		}
+		if !slices.ContainsFunc(profiles, func(p profile) bool { return p.Name == input }) {
+			fmt.Printf("Novo jogador '%s' criado.\n", input)
+		}
+		return input
+	}
+}
+
+// runProfiles implements the profiles command: it lists the profiles, or
+// resets the one named after "reset".
+func runProfiles(args []string, yes bool) int {
+	switch {
+	case len(args) == 0 || (len(args) == 1 && args[0] == "list"):
+		profiles, err := listProfiles()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if len(profiles) == 0 {
+			fmt.Println("Nenhum jogador encontrado.")
+			return 0
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "JOGADOR\tRESOLVIDOS\tPONTUAÇÃO\tÚLTIMA ATIVIDADE")
+		for _, p := range profiles {
+			score, last := "-", "-"
+			if p.Scored {
+				score = strconv.Itoa(p.Score)
+			}
+			if !p.LastActive.IsZero() {
+				last = p.LastActive.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", p.Name, p.Solved, score, last)
+		}
+		w.Flush()
+		return 0
+
+	case len(args) == 2 && args[0] == "reset":
+		name := args[1]
+		if err := checkPlayerName(name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if !yes {
+			fmt.Printf("Apagar o progresso, as pontuações e o histórico de '%s'? [s/N] ", name)
+			answer, _ := readLine()
+			if answer = strings.ToLower(answer); answer != "s" && answer != "sim" {
+				fmt.Println("Nada foi apagado.")
+				return 1
+			}
+		}
+		if err := resetProfile(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Jogador '%s' reiniciado.\n", name)
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, "Usage: start-challenges profiles [--yes] [list | reset <player>]")
+	return 2
+}
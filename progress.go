@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// progressStore is what the player has achieved, kept in progress.json in
+// the player's directory so that it survives quitting and restarts.
+type progressStore struct {
+	Challenges map[string]*challengeProgress `json:"challenges"`
+
+	// player is the profile the progress belongs to, and path where the
+	// store is saved; an empty path keeps it in memory only.
+	player string
+	path   string
+}
+
+// challengeProgress is the record of one challenge.
+type challengeProgress struct {
+	Solved    bool       `json:"solved"`
+	SolvedAt  *time.Time `json:"solved_at,omitempty"`
+	BestScore *int       `json:"best_score,omitempty"`
+
+	// Attempt is the unfinished attempt, resumed when the challenge is
+	// re-entered. It is cleared once the challenge is solved.
+	Attempt *attempt `json:"attempt,omitempty"`
+}
+
+// attempt is how far the player got in a challenge they have not solved yet.
+type attempt struct {
+	StartedAt time.Time `json:"started_at"`
+	Found     []string  `json:"found_flags,omitempty"` // keys from flagKey
+	Hints     int       `json:"hints,omitempty"`
+	Earned    int       `json:"earned,omitempty"`
+	Elapsed   Duration  `json:"elapsed"`
+}
+
+// progressPath returns where a player's progress is saved.
+func progressPath(player string) (string, error) {
+	return playerPath(player, "progress.json")
+}
+
+// loadProgress reads the store at path. A missing file is an empty store.
+func loadProgress(path string) (*progressStore, error) {
+	store := &progressStore{Challenges: make(map[string]*challengeProgress), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	if store.Challenges == nil {
+		store.Challenges = make(map[string]*challengeProgress)
+	}
+	return store, nil
+}
+
+// openProgress loads a player's saved progress. When it cannot be read,
+// a warning is logged and progress is kept in memory only, so a damaged
+// file is not overwritten.
+func openProgress(player string) *progressStore {
+	path, err := progressPath(player)
+	if err == nil {
+		var store *progressStore
+		if store, err = loadProgress(path); err == nil {
+			store.player = player
+			return store
+		}
+	}
+	log.Printf("Warning: progress will not be saved: %v", err)
+	return &progressStore{Challenges: make(map[string]*challengeProgress), player: player}
+}
+
+// save writes the store to disk, replacing the previous file atomically.
+func (s *progressStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("could not create %s: %w", filepath.Dir(s.path), err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not write progress: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// entry returns the record of a challenge, creating it if needed.
+func (s *progressStore) entry(dirName string) *challengeProgress {
+	p := s.Challenges[dirName]
+	if p == nil {
+		p = &challengeProgress{}
+		s.Challenges[dirName] = p
+	}
+	return p
+}
+
+// solved reports whether a challenge has ever been solved.
+func (s *progressStore) solved(dirName string) bool {
+	p := s.Challenges[dirName]
+	return p != nil && p.Solved
+}
+
+// totalScore returns the sum of the best scores.
+func (s *progressStore) totalScore() (total int, scored bool) {
+	for _, p := range s.Challenges {
+		if p.BestScore != nil {
+			total += *p.BestScore
+			scored = true
+		}
+	}
+	return total, scored
+}
+
+// finish records the end of a challenge session.
+func (s *progressStore) finish(dirName string, sess *Session, now time.Time) {
+	p := s.entry(dirName)
+	if inst := sess.inst; inst != nil && inst.Score != nil {
+		if p.Attempt != nil {
+			p.Attempt.Elapsed = Duration(now.Sub(inst.Score.started).Round(time.Second))
+		}
+		if inst.Score.Enabled() {
+			total := inst.Score.Total()
+			if p.BestScore == nil || total > *p.BestScore {
+				p.BestScore = &total
+			}
+		}
+	}
+	switch sess.State {
+	case StateSolved, StateKeptAlive:
+		if !p.Solved {
+			p.Solved, p.SolvedAt = true, &now
+		}
+		p.Attempt = nil
+	case StateTimedOut:
+		// The time is up; the next attempt starts over.
+		p.Attempt = nil
+	}
+	if err := s.save(); err != nil {
+		log.Printf("Warning: could not save progress: %v", err)
+	}
+}
+
+// resumeAttempt returns the attempt the instance's session continues, or a
+// new one if there is none.
+func (inst *ChallengeInstance) resumeAttempt(now time.Time) *attempt {
+	if inst.store == nil {
+		return &attempt{StartedAt: now}
+	}
+	p := inst.store.entry(inst.DirName)
+	if p.Attempt == nil {
+		p.Attempt = &attempt{StartedAt: now}
+	}
+	return p.Attempt
+}
+
+// saveAttempt copies the session's found flags and score into its attempt
+// and saves the progress store.
+func (inst *ChallengeInstance) saveAttempt(att *attempt, found map[int]bool, now time.Time) {
+	att.Found = att.Found[:0]
+	for i := range inst.Challenge.Flags {
+		if found[i] {
+			att.Found = append(att.Found, flagKey(inst.Challenge.Flags, i))
+		}
+	}
+	att.Hints, att.Earned = inst.Score.Hints, inst.Score.Earned
+	att.Elapsed = Duration(now.Sub(inst.Score.started).Round(time.Second))
+	if inst.store == nil {
+		return
+	}
+	if err := inst.store.save(); err != nil {
+		log.Printf("Warning: could not save progress: %v", err)
+	}
+}
+
+// flagKey identifies flag i of a challenge in the progress store: its id,
+// or its position when it has none.
+func flagKey(flags []FlagSpec, i int) string {
+	if flags[i].ID != "" {
+		return flags[i].ID
+	}
+	return strconv.Itoa(i + 1)
+}
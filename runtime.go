@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// ChallengeRuntime is a backend capable of bringing a challenge environment
+// up and down. Each kind of challenge (Dockerfile, Docker Compose, ...)
+// provides one implementation and registers it with registerRuntime.
+//
+// Runtimes are stateless; everything specific to a running challenge lives
+// in the ChallengeInstance passed to each method.
+type ChallengeRuntime interface {
+	// Name returns a short identifier for the runtime, used in logs.
+	Name() string
+	// Detect reports whether the challenge directory is handled by this runtime.
+	Detect(challengePath string) bool
+	// Prepare does any work needed before starting, such as building images.
+	Prepare(ctx context.Context, inst *ChallengeInstance) error
+	// Start brings the challenge environment up.
+	Start(ctx context.Context, inst *ChallengeInstance) error
+	// Endpoints lists the addresses the player can use to reach the challenge.
+	Endpoints(inst *ChallengeInstance) []Endpoint
+	// Stop tears the running environment down, keeping cached artifacts.
+	Stop(ctx context.Context, inst *ChallengeInstance) error
+}
+
+// ChallengeInstance holds the state of a single challenge run.
+type ChallengeInstance struct {
+	// SessionID identifies the run in the journal.
+	SessionID string
+	DirName   string
+	Path      string
+	Challenge Challenge
+	Runtime   ChallengeRuntime
+
+	ForceBuild bool
+	Debug      bool
+	Silent     bool
+
+	// flag is the flag generated for this run and flagFile the host file
+	// holding it, when the challenge uses a dynamic flag.
+	flag     string
+	flagFile string
+
+	// ports maps the host ports declared by the challenge to those
+	// allocated to this run.
+	ports map[int]int
+
+	// ttl is how long the instance may run and expires when that runs
+	// out; idleTimeout is how long it may run without the player using it.
+	ttl         time.Duration
+	expires     time.Time
+	idleTimeout time.Duration
+
+	// Score is the running score, set once the player gets the prompt.
+	Score *Score
+	// store keeps the player's progress; nil when it is not recorded.
+	store *progressStore
+
+	cli *client.Client
+}
+
+// player returns the profile whose journal and scores the instance writes
+// to: the owner of its progress store, or the active player.
+func (inst *ChallengeInstance) player() string {
+	if inst.store != nil && inst.store.player != "" {
+		return inst.store.player
+	}
+	return activePlayer
+}
+
+// verbose reports whether Docker operations should be printed for this instance.
+func (inst *ChallengeInstance) verbose() bool {
+	return inst.Debug && !inst.Silent
+}
+
+// progress reports what is happening to one part of the environment, such
+// as a compose service, unless the instance is silent.
+func (inst *ChallengeInstance) progress(part, message string) {
+	if !inst.Silent {
+		fmt.Printf("   • %s: %s\n", part, message)
+	}
+}
+
+// Endpoint is an address exposed by a running challenge.
+type Endpoint struct {
+	Label string `json:"label,omitempty"`
+	URL   string `json:"url"`
+}
+
+// runtimes holds the registered backends in detection order.
+var runtimes []ChallengeRuntime
+
+func init() {
+	// Compose goes first: a compose challenge may also ship Dockerfiles
+	// for its services.
+	registerRuntime(composeRuntime{})
+	registerRuntime(dockerfileRuntime{})
+}
+
+// registerRuntime adds a backend to the registry. Runtimes registered first
+// take precedence when more than one of them detects a challenge directory.
+func registerRuntime(rt ChallengeRuntime) {
+	runtimes = append(runtimes, rt)
+}
+
+// detectRuntime returns the first registered runtime that handles the
+// challenge directory, or nil if none does.
+func detectRuntime(challengePath string) ChallengeRuntime {
+	for _, rt := range runtimes {
+		if rt.Detect(challengePath) {
+			return rt
+		}
+	}
+	return nil
+}
+
+// portEndpoints builds the endpoint list for the challenge's ports, on the
+// host ports allocated to the instance, adding a short description for the
+// ports we know about.
+func (inst *ChallengeInstance) portEndpoints() []Endpoint {
+	ports := inst.Challenge.Ports
+	endpoints := make([]Endpoint, 0, len(ports))
+	for _, port := range ports {
+		url := fmt.Sprintf("http://127.0.0.1:%d", inst.hostPort(port))
+		switch port {
+		case 9001:
+			endpoints = append(endpoints, Endpoint{Label: "Console Web", URL: url})
+		case 9000:
+			endpoints = append(endpoints, Endpoint{Label: "API Endpoint", URL: url})
+		default:
+			endpoints = append(endpoints, Endpoint{URL: url})
+		}
+	}
+	return endpoints
+}
+
+// fileIn reports whether name exists as a regular file inside dir.
+func fileIn(dir, name string) bool {
+	return fileExists(filepath.Join(dir, name))
+}
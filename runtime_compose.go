@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-connections/nat"
+)
+
+// composeWaitTimeout bounds how long a service waits for its dependencies
+// to become healthy or to finish.
+const composeWaitTimeout = 3 * time.Minute
+
+// composeRuntime runs challenges defined by a docker-compose.yml file. The
+// compose file is parsed by the platform and its networks, volumes and
+// containers are created through the Docker API, so the compose plugin does
+// not need to be installed.
+type composeRuntime struct{}
+
+func (composeRuntime) Name() string { return "compose" }
+
+func (composeRuntime) Detect(challengePath string) bool {
+	return fileIn(challengePath, "docker-compose.yml")
+}
+
+// Prepare removes containers left by older versions of the platform and
+// makes sure the image of every service is available, building or pulling
+// it as needed. Images are shared by every instance of the challenge.
+func (composeRuntime) Prepare(ctx context.Context, inst *ChallengeInstance) error {
+	cf, err := loadComposeFile(inst.Path)
+	if err != nil {
+		return err
+	}
+	project := composeProjectName(cf, inst.DirName)
+	if !inst.Silent {
+		fmt.Println("docker-compose.yml detectado, iniciando ambiente...")
+	}
+
+	order, err := cf.startOrder()
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		// Containers named without an instance suffix may not carry our
+		// labels either.
+		cleanup(ctx, inst.cli, composeContainerName(project, name, cf.Services[name]), "", false, inst.Debug)
+	}
+	for _, name := range order {
+		svc := cf.Services[name]
+		if svc.Build != nil {
+			tag := inst.composeImageTag(project, name)
+			exists, err := imageExists(ctx, inst.cli, tag)
+			buildArgs := inst.flagBuildArgs(name)
+			if err != nil || inst.ForceBuild || !exists || buildArgs != nil {
+				inst.progress(name, "construindo imagem...")
+				contextPath := filepath.Join(inst.Path, svc.Build.Context)
+				dockerfile := svc.Build.Dockerfile
+				if dockerfile == "" {
+					dockerfile = "Dockerfile"
+				}
+				labels := inst.resourceLabels(map[string]string{labelProject: project, labelService: name})
+				if buildArgs != nil {
+					labels = inst.instanceLabels(map[string]string{labelProject: inst.instanceName(project), labelService: name})
+				}
+				if err := buildImage(ctx, inst.cli, contextPath, dockerfile, tag, labels, buildArgs, inst.verbose()); err != nil {
+					inst.event(Event{Type: eventBuild, Service: name, Error: err.Error()})
+					return fmt.Errorf("service '%s': %w", name, err)
+				}
+				inst.event(Event{Type: eventBuild, Service: name})
+			}
+			continue
+		}
+		exists, err := imageExists(ctx, inst.cli, svc.Image)
+		if err == nil && exists {
+			continue
+		}
+		inst.progress(name, fmt.Sprintf("baixando imagem %s...", svc.Image))
+		if err := pullImage(ctx, inst.cli, svc.Image, inst.verbose()); err != nil {
+			inst.event(Event{Type: eventPull, Service: name, Error: err.Error()})
+			return fmt.Errorf("service '%s': %w", name, err)
+		}
+		inst.event(Event{Type: eventPull, Service: name})
+	}
+	return nil
+}
+
+// Start creates the instance's networks and volumes, then starts each
+// service once the services it depends on are ready. Every resource is
+// named after the instance, and published ports are moved to the host
+// ports allocated to it.
+func (composeRuntime) Start(ctx context.Context, inst *ChallengeInstance) error {
+	cf, err := loadComposeFile(inst.Path)
+	if err != nil {
+		return err
+	}
+	project := composeProjectName(cf, inst.DirName)
+	instance := inst.instanceName(project)
+	projectLabels := inst.instanceLabels(map[string]string{labelProject: instance})
+
+	order, err := cf.startOrder()
+	if err != nil {
+		return err
+	}
+	published, err := composeHostPorts(cf)
+	if err != nil {
+		return err
+	}
+	if err := inst.allocatePorts(published); err != nil {
+		return err
+	}
+
+	// Networks: the declared ones, plus "default" for services without any.
+	networks := sortedKeys(cf.Networks)
+	for _, svc := range cf.Services {
+		if len(svc.Networks) == 0 {
+			if _, ok := cf.Networks["default"]; !ok {
+				networks = append(networks, "default")
+			}
+			break
+		}
+	}
+	for _, name := range networks {
+		driver := "bridge"
+		if n := cf.Networks[name]; n != nil && n.Driver != "" {
+			driver = n.Driver
+		}
+		if _, err := inst.cli.NetworkCreate(ctx, instance+"_"+name, network.CreateOptions{Driver: driver, Labels: projectLabels}); err != nil {
+			return fmt.Errorf("could not create network '%s': %w", name, err)
+		}
+	}
+
+	// Volumes: the declared ones, plus any named volume a service mounts.
+	mounts := make(map[string][]composeMount)
+	volumes := make(map[string]bool)
+	for name := range cf.Volumes {
+		volumes[name] = true
+	}
+	for name, svc := range cf.Services {
+		for _, spec := range svc.Volumes {
+			m, err := parseComposeMount(spec, inst.Path)
+			if err != nil {
+				return fmt.Errorf("service '%s': %w", name, err)
+			}
+			if !m.Bind && m.Source != "" {
+				volumes[m.Source] = true
+			}
+			mounts[name] = append(mounts[name], m)
+		}
+	}
+	for _, name := range sortedKeys(volumes) {
+		if _, err := inst.cli.VolumeCreate(ctx, volume.CreateOptions{Name: instance + "_" + name, Labels: projectLabels}); err != nil {
+			return fmt.Errorf("could not create volume '%s': %w", name, err)
+		}
+	}
+
+	started := make(map[string]string) // service name -> container ID
+	for _, name := range order {
+		svc := cf.Services[name]
+		for _, dep := range sortedKeys(svc.DependsOn) {
+			condition := svc.DependsOn[dep]
+			if condition == conditionStarted {
+				continue
+			}
+			inst.progress(name, fmt.Sprintf("aguardando %s...", dep))
+			if err := waitForService(ctx, inst.cli, started[dep], condition); err != nil {
+				return fmt.Errorf("service '%s' dependency '%s': %w", name, dep, err)
+			}
+		}
+
+		id, err := startComposeService(ctx, inst, project, name, svc, mounts[name])
+		if err != nil {
+			inst.event(Event{Type: eventContainerStart, Service: name, Error: err.Error()})
+			return fmt.Errorf("service '%s': %w", name, err)
+		}
+		inst.event(Event{Type: eventContainerStart, Service: name})
+		started[name] = id
+		inst.progress(name, "iniciado")
+	}
+	return nil
+}
+
+// startComposeService creates and starts the container for one service.
+func startComposeService(ctx context.Context, inst *ChallengeInstance, project, name string, svc *composeService, mounts []composeMount) (string, error) {
+	instance := inst.instanceName(project)
+	imageRef := svc.Image
+	if svc.Build != nil {
+		imageRef = inst.composeImageTag(project, name)
+	}
+	exposedPorts, portBindings, err := nat.ParsePortSpecs(svc.Ports)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse port specs: %w", err)
+	}
+	for _, bindings := range portBindings {
+		for i, b := range bindings {
+			if port, err := strconv.Atoi(b.HostPort); err == nil {
+				bindings[i].HostPort = strconv.Itoa(inst.hostPort(port))
+			}
+		}
+	}
+	health, err := svc.Healthcheck.healthConfig()
+	if err != nil {
+		return "", err
+	}
+
+	config := &container.Config{
+		Image:        imageRef,
+		Hostname:     svc.Hostname,
+		Env:          append(slices.Clone([]string(svc.Environment)), inst.flagEnv(name)...),
+		ExposedPorts: exposedPorts,
+		Labels:       inst.instanceLabels(map[string]string{labelProject: instance, labelService: name}),
+		Healthcheck:  health,
+	}
+	if len(svc.Command.Args) > 0 {
+		config.Cmd = svc.Command.Args
+	}
+	if len(svc.Entrypoint.Args) > 0 {
+		config.Entrypoint = svc.Entrypoint.Args
+	}
+
+	hostConfig := &container.HostConfig{PortBindings: portBindings, Mounts: inst.flagMounts(name)}
+	for _, m := range mounts {
+		mnt := mount.Mount{Type: mount.TypeVolume, Source: m.Source, Target: m.Target, ReadOnly: m.ReadOnly}
+		if m.Bind {
+			mnt.Type = mount.TypeBind
+		} else if m.Source != "" {
+			mnt.Source = instance + "_" + m.Source
+		}
+		hostConfig.Mounts = append(hostConfig.Mounts, mnt)
+	}
+
+	// Services are reachable from each other by service name on every
+	// network they share.
+	networks := []string(svc.Networks)
+	if len(networks) == 0 {
+		networks = []string{"default"}
+	}
+	endpoint := func() *network.EndpointSettings {
+		return &network.EndpointSettings{Aliases: []string{name}}
+	}
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{instance + "_" + networks[0]: endpoint()},
+	}
+
+	resp, err := inst.cli.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, inst.instanceName(composeContainerName(project, name, svc)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	for _, n := range networks[1:] {
+		if err := inst.cli.NetworkConnect(ctx, instance+"_"+n, resp.ID, endpoint()); err != nil {
+			return "", fmt.Errorf("failed to connect to network '%s': %w", n, err)
+		}
+	}
+	if err := inst.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// waitForService blocks until a started service container meets a
+// depends_on condition.
+func waitForService(ctx context.Context, cli *client.Client, id, condition string) error {
+	ctx, cancel := context.WithTimeout(ctx, composeWaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		info, err := cli.ContainerInspect(ctx, id)
+		if err != nil {
+			return err
+		}
+		state := info.State
+		switch condition {
+		case conditionHealthy:
+			if state.Health == nil {
+				return fmt.Errorf("container has no healthcheck")
+			}
+			switch state.Health.Status {
+			case container.Healthy:
+				return nil
+			case container.Unhealthy:
+				return fmt.Errorf("container is unhealthy")
+			}
+			if !state.Running {
+				return fmt.Errorf("container exited with code %d", state.ExitCode)
+			}
+		case conditionCompleted:
+			if !state.Running && state.Status == container.StateExited {
+				if state.ExitCode != 0 {
+					return fmt.Errorf("container exited with code %d", state.ExitCode)
+				}
+				return nil
+			}
+		default:
+			return fmt.Errorf("unsupported depends_on condition '%s'", condition)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s", condition)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (composeRuntime) Endpoints(inst *ChallengeInstance) []Endpoint {
+	return inst.portEndpoints()
+}
+
+// Stop removes the instance's containers, networks and volumes, like
+// `docker compose down -v`, and the images built for it alone. Other
+// instances of the challenge keep running.
+func (composeRuntime) Stop(ctx context.Context, inst *ChallengeInstance) error {
+	cf, err := loadComposeFile(inst.Path)
+	if err != nil {
+		return err
+	}
+	instance := inst.instanceName(composeProjectName(cf, inst.DirName))
+	args := labelFilter(inst.resourceLabels(map[string]string{labelProject: instance}))
+	if _, err := removeLabeledResources(ctx, inst.cli, args, false, inst.Debug); err != nil {
+		return err
+	}
+	return inst.removeSessionImages(ctx)
+}
+
+// composeHostPorts lists the host ports published by the services, in order.
+func composeHostPorts(cf *composeFile) ([]int, error) {
+	var ports []int
+	for _, name := range sortedKeys(cf.Services) {
+		_, bindings, err := nat.ParsePortSpecs(cf.Services[name].Ports)
+		if err != nil {
+			return nil, fmt.Errorf("service '%s': failed to parse port specs: %w", name, err)
+		}
+		for _, b := range bindings {
+			for _, binding := range b {
+				if port, err := strconv.Atoi(binding.HostPort); err == nil {
+					ports = append(ports, port)
+				}
+			}
+		}
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports), nil
+}
+
+// composeContainerName names the container of a service. Instances add
+// their own suffix to it.
+func composeContainerName(project, name string, svc *composeService) string {
+	if svc.ContainerName != "" {
+		return svc.ContainerName
+	}
+	return "challenge-container-" + project + "-" + name
+}
+
+// composeImageTag names the image built for a service. An image with the
+// dynamic flag baked in belongs to one instance and is named after it.
+func (inst *ChallengeInstance) composeImageTag(project, service string) string {
+	if inst.flagBuildArgs(service) != nil {
+		project = inst.instanceName(project)
+	}
+	return "challenge-" + project + "-" + service + ":latest"
+}
+
+// pullImage pulls an image from its registry, showing progress in debug mode.
+func pullImage(ctx context.Context, cli *client.Client, ref string, debug bool) error {
+	rc, err := cli.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return fmt.Errorf("image pull request failed: %w", err)
+	}
+	defer rc.Close()
+	var out io.Writer = io.Discard
+	if debug {
+		out = os.Stdout
+	}
+	return jsonmessage.DisplayJSONMessagesStream(rc, out, 0, false, nil)
+}
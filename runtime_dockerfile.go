@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// dockerfileRuntime runs challenges built from a single Dockerfile.
+type dockerfileRuntime struct{}
+
+func (dockerfileRuntime) Name() string { return "dockerfile" }
+
+func (dockerfileRuntime) Detect(challengePath string) bool {
+	return fileIn(challengePath, "Dockerfile")
+}
+
+// imageTag names the challenge image. An image with the dynamic flag baked
+// in belongs to one session and is named after it, so that concurrent
+// sessions never run each other's builds.
+func (dockerfileRuntime) imageTag(inst *ChallengeInstance) string {
+	name := "challenge-" + strings.ToLower(inst.DirName)
+	if inst.flagBuildArgs("") != nil {
+		name = inst.instanceName(name)
+	}
+	return name + ":latest"
+}
+
+func (dockerfileRuntime) containerName(inst *ChallengeInstance) string {
+	return inst.instanceName("challenge-container-" + strings.ToLower(inst.DirName))
+}
+
+// Prepare removes any leftover container and builds the image if needed.
+func (rt dockerfileRuntime) Prepare(ctx context.Context, inst *ChallengeInstance) error {
+	imageTag := rt.imageTag(inst)
+	cleanup(ctx, inst.cli, rt.containerName(inst), "", false, inst.Debug)
+
+	exists, err := imageExists(ctx, inst.cli, imageTag)
+	if err != nil {
+		log.Printf("Warning: Could not check if image '%s' exists: %v. Attempting to build.", imageTag, err)
+	}
+	// A flag passed as a build argument is baked into the image, so the
+	// image cannot be reused across sessions.
+	buildArgs := inst.flagBuildArgs("")
+	labels := inst.resourceLabels(nil)
+	if buildArgs != nil {
+		labels = inst.instanceLabels(nil)
+	}
+	if inst.ForceBuild || !exists || buildArgs != nil {
+		if inst.ForceBuild && inst.verbose() {
+			fmt.Print("Build forced by user with --build flag")
+		}
+		if err := buildImage(ctx, inst.cli, inst.Path, "Dockerfile", imageTag, labels, buildArgs, inst.verbose()); err != nil {
+			inst.event(Event{Type: eventBuild, Error: err.Error()})
+			return fmt.Errorf("failed to build Docker image: %w", err)
+		}
+		inst.event(Event{Type: eventBuild})
+	} else if inst.verbose() {
+		fmt.Printf("Using existing image '%s'. Use --build to force a rebuild.\n", imageTag)
+	}
+
+	if len(inst.Challenge.Ports) == 0 {
+		return fmt.Errorf("no ports defined in challenge.json")
+	}
+	return nil
+}
+
+func (rt dockerfileRuntime) Start(ctx context.Context, inst *ChallengeInstance) error {
+	port := inst.Challenge.Ports[0]
+	if err := inst.allocatePorts([]int{port}); err != nil {
+		return err
+	}
+	_, err := runContainer(ctx, inst.cli, rt.imageTag(inst), rt.containerName(inst), inst.hostPort(port), inst.flagEnv(""), inst.flagMounts(""), inst.instanceLabels(nil), inst.verbose())
+	if err != nil {
+		inst.event(Event{Type: eventContainerStart, Error: err.Error()})
+		return err
+	}
+	inst.event(Event{Type: eventContainerStart})
+	return nil
+}
+
+func (dockerfileRuntime) Endpoints(inst *ChallengeInstance) []Endpoint {
+	return inst.portEndpoints()
+}
+
+// Stop removes the container, and the image when it was built for this
+// session alone.
+func (rt dockerfileRuntime) Stop(ctx context.Context, inst *ChallengeInstance) error {
+	cleanup(ctx, inst.cli, rt.containerName(inst), "", false, inst.Debug)
+	return inst.removeSessionImages(ctx)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// challengeTypes lists the templates `new` can scaffold from.
+var challengeTypes = []string{"dockerfile", "compose", "static"}
+
+// slugPattern is what a challenge directory name may look like.
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// scaffoldData is what the challenge templates are rendered with.
+type scaffoldData struct {
+	Slug string
+	Name string
+	// FlagTemplate is the dynamic flag template of the new challenge.
+	FlagTemplate string
+	Port         int
+}
+
+// runNew implements the new command, which creates a challenge directory
+// from the embedded templates and adds it to config.json.
+func runNew(root, kind, slug, name string) int {
+	dir, err := scaffoldChallenge(root, kind, slug, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Desafio '%s' criado em %s.\n", slug, dir)
+	fmt.Println("Edite os arquivos gerados e rode 'start-challenges validate' e 'start-challenges lint' antes de publicar.")
+	return 0
+}
+
+// scaffoldChallenge renders the templates of a challenge type into a new
+// directory under root and lists it in config.json.
+func scaffoldChallenge(root, kind, slug, name string) (string, error) {
+	if !slices.Contains(challengeTypes, kind) {
+		return "", fmt.Errorf("unknown challenge type '%s' (expected one of %s)", kind, strings.Join(challengeTypes, ", "))
+	}
+	if !slugPattern.MatchString(slug) {
+		return "", fmt.Errorf("invalid slug '%s': use lowercase letters, digits and dashes", slug)
+	}
+	config, err := loadConfig(root)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(config.Challenges, slug) {
+		return "", fmt.Errorf("challenge '%s' is already listed in config.json", slug)
+	}
+	dir := config.ChallengePath(slug)
+	if _, err := os.Stat(dir); err == nil {
+		return "", fmt.Errorf("%s already exists", dir)
+	}
+
+	if name == "" {
+		name = nameFromSlug(slug)
+	}
+	data := scaffoldData{
+		Slug:         slug,
+		Name:         name,
+		FlagTemplate: "WSS{" + strings.ReplaceAll(slug, "-", "_") + "_{{random:12}}}",
+		Port:         freePort(config),
+	}
+	if err := renderTemplates(filepath.Join("templates", kind), dir, data); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	config.Challenges = insertChallenge(config.Challenges, slug)
+	if err := saveConfig(config); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// renderTemplates renders every *.tmpl file under src into dst, keeping the
+// directory layout and dropping the .tmpl extension.
+func renderTemplates(src, dst string, data scaffoldData) error {
+	funcs := template.FuncMap{
+		"json": func(s string) (string, error) {
+			b, err := json.Marshal(s)
+			return string(b), err
+		},
+	}
+	return fs.WalkDir(templateFS, src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, strings.TrimSuffix(rel, ".tmpl"))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		text, err := templateFS.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(rel).Funcs(funcs).Parse(string(text))
+		if err != nil {
+			return fmt.Errorf("template %s: %w", path, err)
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return tmpl.Execute(f, data)
+	})
+}
+
+// nameFromSlug turns "03-sql-injection" into "Sql Injection".
+func nameFromSlug(slug string) string {
+	words := strings.Split(slug, "-")
+	for len(words) > 1 && strings.Trim(words[0], "0123456789") == "" {
+		words = words[1:]
+	}
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// freePort picks the first port from 8080 up that no other challenge uses.
+func freePort(config Config) int {
+	used := make(map[int]bool)
+	for _, dirName := range config.Challenges {
+		if challenge, err := loadChallenge(config.ChallengePath(dirName)); err == nil {
+			for _, port := range challenge.Ports {
+				used[port] = true
+			}
+		}
+	}
+	port := 8080
+	for used[port] {
+		port++
+	}
+	return port
+}
+
+// insertChallenge adds slug to the challenge list. Lists kept in sorted
+// order (as with numbered directories) stay sorted; otherwise the slug is
+// appended.
+func insertChallenge(challenges []string, slug string) []string {
+	if !slices.IsSorted(challenges) {
+		return append(challenges, slug)
+	}
+	i, _ := slices.BinarySearch(challenges, slug)
+	return slices.Insert(challenges, i, slug)
+}
+
+// saveConfig writes config.json back to the challenges root.
+func saveConfig(config Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(config.Root, "config.json"), data, 0o644)
+}
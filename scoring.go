@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ScoringRules sets how many points a challenge is worth and what reduces
+// them.
+type ScoringRules struct {
+	// Points is the base value of the challenge. In multi-flag challenges
+	// it is shared equally by the flags that do not set their own points.
+	Points int `json:"points"`
+	// HintPenalty is taken off the score for every hint revealed.
+	HintPenalty int `json:"hint_penalty"`
+	// DecayPerMinute is taken off each flag's points for every full minute
+	// it takes to find it past DecayAfter, down to MinPoints.
+	DecayAfter     Duration `json:"decay_after"`
+	DecayPerMinute int      `json:"decay_per_minute"`
+	MinPoints      int      `json:"min_points"`
+}
+
+// Score is the running score of one challenge session.
+type Score struct {
+	rules   ScoringRules
+	flags   []FlagSpec
+	started time.Time
+
+	Earned int // points from the flags found
+	Hints  int // hints revealed
+}
+
+// newScore starts scoring a challenge session at the given time.
+func newScore(challenge Challenge, started time.Time) *Score {
+	flags := challenge.Flags
+	if len(flags) == 0 {
+		flags = []FlagSpec{challenge.Flag}
+	}
+	return &Score{rules: challenge.Scoring, flags: flags, started: started}
+}
+
+// Enabled reports whether the challenge is worth any points, so that
+// challenges without scoring do not show a score.
+func (s *Score) Enabled() bool {
+	if s.rules.Points != 0 {
+		return true
+	}
+	for _, f := range s.flags {
+		if f.Points != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Max returns the most points the challenge can give.
+func (s *Score) Max() int {
+	total := 0
+	for i := range s.flags {
+		total += s.base(i)
+	}
+	return total
+}
+
+// base returns the points flag i is worth before any decay.
+func (s *Score) base(i int) int {
+	if s.flags[i].Points != 0 {
+		return s.flags[i].Points
+	}
+	shared := 0
+	for _, f := range s.flags {
+		if f.Points == 0 {
+			shared++
+		}
+	}
+	return s.rules.Points / shared
+}
+
+// FlagFound adds the points of flag i, found at the given time, and
+// returns them.
+func (s *Score) FlagFound(i int, at time.Time) int {
+	points := s.base(i)
+	over := at.Sub(s.started) - time.Duration(s.rules.DecayAfter)
+	if s.rules.DecayPerMinute > 0 && over > 0 {
+		decayed := points - s.rules.DecayPerMinute*int(over/time.Minute)
+		points = max(decayed, min(s.rules.MinPoints, points))
+	}
+	s.Earned += points
+	return points
+}
+
+// HintRevealed records a revealed hint and returns the points it costs.
+func (s *Score) HintRevealed() int {
+	s.Hints++
+	return s.rules.HintPenalty
+}
+
+// Total returns the current score, which never goes below zero.
+func (s *Score) Total() int {
+	return max(s.Earned-s.Hints*s.rules.HintPenalty, 0)
+}
+
+// scoreRecord is a line of the scores file.
+type scoreRecord struct {
+	Time      time.Time `json:"time"`
+	Challenge string    `json:"challenge"`
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	Score     int       `json:"score"`
+	Max       int       `json:"max"`
+	Hints     int       `json:"hints"`
+	Seconds   int       `json:"seconds"`
+}
+
+// recordScore appends the final score of a finished session to
+// scores.jsonl in the player's directory.
+func recordScore(inst *ChallengeInstance, state SessionState, now time.Time) error {
+	s := inst.Score
+	if s == nil {
+		return nil
+	}
+	path, err := playerPath(inst.player(), "scores.jsonl")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open scores file: %w", err)
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(scoreRecord{
+		Time:      now.UTC(),
+		Challenge: inst.DirName,
+		Name:      inst.Challenge.Name,
+		State:     strings.ToLower(state.String()),
+		Score:     s.Total(),
+		Max:       s.Max(),
+		Hints:     s.Hints,
+		Seconds:   int(now.Sub(s.started).Seconds()),
+	})
+}
@@ -0,0 +1,874 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+//go:embed web
+var webFS embed.FS
+
+// serveShutdownTimeout bounds how long the server waits for requests in
+// flight when it is stopped.
+const serveShutdownTimeout = 10 * time.Second
+
+// webServer serves the platform over HTTP. Challenges are started, played
+// and stopped from the browser through the same runtimes, sessions and flag
+// checks as the main menu. Each player has their own progress and
+// instances; requests name the player with a cookie set from the web UI or
+// the X-Player header, and default to the --player of the server.
+type webServer struct {
+	ctx        context.Context
+	cli        *client.Client
+	config     Config
+	forceBuild bool
+	debug      bool
+	pages      map[string]*template.Template
+
+	// token is the secret every request must carry, and player the
+	// profile of requests that name none.
+	token  string
+	player string
+
+	// starting tracks the challenges being started in the background.
+	starting sync.WaitGroup
+
+	mu      sync.Mutex
+	players map[string]*webPlayer
+}
+
+// webPlayer is a player of the web UI: their progress and the challenges
+// they started. Its fields are guarded by the server's mu.
+type webPlayer struct {
+	name     string
+	store    *progressStore
+	sessions map[string]*webSession // by challenge directory
+	flashes  map[string]flash       // by challenge directory
+}
+
+// webSession is a challenge started from the web UI. Its session is nil
+// while the environment is still being started.
+type webSession struct {
+	sess    *Session
+	play    *play
+	started time.Time
+	active  time.Time   // last time the player used the challenge
+	timer   *time.Timer // ends the session when it runs out of time
+
+	// submitting lets one flag of the session be checked at a time, so
+	// that every miss counts towards the backoff before the next flag
+	// is let through. It is taken before s.mu.
+	submitting sync.Mutex
+}
+
+// flash is a message shown once, on the next view of a challenge page.
+type flash struct {
+	Kind  string // "ok", "error" or "info"
+	Lines []string
+}
+
+// Web states of a challenge.
+const (
+	webStopped  = "stopped"
+	webStarting = "starting"
+	webRunning  = "running"
+	webKept     = "kept" // solved, with its environment kept running
+)
+
+func newWebServer(ctx context.Context, cli *client.Client, config Config, token, player string, forceBuild, debug bool) (*webServer, error) {
+	s := &webServer{
+		ctx:        ctx,
+		cli:        cli,
+		config:     config,
+		forceBuild: forceBuild,
+		debug:      debug,
+		pages:      make(map[string]*template.Template),
+		token:      token,
+		player:     player,
+		players:    make(map[string]*webPlayer),
+	}
+	for _, name := range []string{"index.html", "challenge.html"} {
+		t, err := template.ParseFS(webFS, "web/layout.html", "web/"+name)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse web page %s: %w", name, err)
+		}
+		s.pages[name] = t
+	}
+	return s, nil
+}
+
+// handler routes the web UI and the REST API.
+func (s *webServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	root, _ := fs.Sub(webFS, "web")
+	mux.Handle("GET /static/", http.FileServerFS(root))
+	mux.HandleFunc("GET /{$}", s.index)
+	mux.HandleFunc("POST /player", s.switchPlayer)
+	mux.HandleFunc("GET /challenges/{slug}", s.challengePage)
+	mux.HandleFunc("POST /challenges/{slug}/start", s.start)
+	mux.HandleFunc("POST /challenges/{slug}/stop", s.stop)
+	mux.HandleFunc("POST /challenges/{slug}/flag", s.submitFlag)
+	mux.HandleFunc("POST /challenges/{slug}/hint", s.revealHint)
+	s.registerAPI(mux)
+	return s.authorize(mux)
+}
+
+// Cookies set by the web UI.
+const (
+	tokenCookie  = "wss-ctf-token"
+	playerCookie = "wss-ctf-player"
+)
+
+// authorize lets a request through when it carries the server's token: in
+// an "Authorization: Bearer" header, as API clients send it, or in the
+// cookie set when the browser first opens the link with ?token=. Forms
+// posted from other sites are refused, so that a page the player visits
+// cannot act on their behalf.
+func (s *webServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.validToken(token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token := r.URL.Query().Get("token"); r.Method == http.MethodGet && s.validToken(token) {
+			http.SetCookie(w, &http.Cookie{Name: tokenCookie, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+			u := *r.URL
+			q := u.Query()
+			q.Del("token")
+			u.RawQuery = q.Encode()
+			http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+			return
+		}
+		if c, err := r.Cookie(tokenCookie); err == nil && s.validToken(c.Value) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead && !sameOrigin(r) {
+				deny(w, r, http.StatusForbidden, "forbidden", "cross-origin request refused")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		deny(w, r, http.StatusUnauthorized, "unauthorized", "missing or invalid token")
+	})
+}
+
+func (s *webServer) validToken(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// sameOrigin reports whether a browser request comes from a page of this
+// server. Requests that say nothing about their origin come from other
+// clients, which the SameSite cookie keeps from carrying the token anyway.
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin" || site == "none"
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	}
+	return true
+}
+
+// deny answers a request that is not let through, in JSON for the API.
+func deny(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeError(w, status, code, "%s", message)
+		return
+	}
+	if status == http.StatusUnauthorized {
+		message = "Acesso negado. Abra o endereço com o token mostrado quando o servidor foi iniciado."
+	} else {
+		message = "Requisição recusada: ela não veio de uma página desta plataforma."
+	}
+	http.Error(w, message, status)
+}
+
+// newServeToken returns a random token for a server started without one.
+func newServeToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// runServe implements the serve command. It serves the web UI on addr until
+// interrupted, then stops every challenge started from it. Requests must
+// carry token; one is generated when it is empty.
+func runServe(ctx context.Context, cli *client.Client, config Config, addr, token string, forceBuild, debug bool) int {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if token == "" {
+		var err error
+		if token, err = newServeToken(); err != nil {
+			log.Printf("Error: %v", err)
+			return 1
+		}
+	}
+	s, err := newWebServer(context.WithoutCancel(ctx), cli, config, token, activePlayer, forceBuild, debug)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return 1
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("Error: could not listen on %s: %v", addr, err)
+		return 1
+	}
+	startReaper(ctx, cli, debug)
+	srv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	fmt.Printf("Servindo a plataforma em http://%s/?token=%s\n", ln.Addr(), token)
+	fmt.Printf("Jogador padrão: '%s'. Pressione Ctrl+C para encerrar.\n", s.player)
+
+	errs := make(chan error, 1)
+	go func() { errs <- srv.Serve(ln) }()
+	status := 0
+	select {
+	case err := <-errs:
+		log.Printf("Error: %v", err)
+		status = 1
+	case <-ctx.Done():
+		fmt.Println("\nEncerrando o servidor...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	s.stopAll()
+	return status
+}
+
+// stopAll ends every challenge started from the web UI, once those still
+// starting are up.
+func (s *webServer) stopAll() {
+	s.starting.Wait()
+	type running struct {
+		pl  *webPlayer
+		dir string
+		ws  *webSession
+	}
+	var sessions []running
+	s.mu.Lock()
+	for _, name := range sortedKeys(s.players) {
+		pl := s.players[name]
+		for _, dir := range sortedKeys(pl.sessions) {
+			sessions = append(sessions, running{pl, dir, pl.sessions[dir]})
+		}
+	}
+	s.mu.Unlock()
+	for _, r := range sessions {
+		s.end(r.pl, r.dir, r.ws, OutcomeQuit)
+	}
+}
+
+// requestPlayer returns the player a request is for, or answers 400 when
+// it names an invalid one.
+func (s *webServer) requestPlayer(w http.ResponseWriter, r *http.Request) (*webPlayer, bool) {
+	name := r.Header.Get("X-Player")
+	if c, err := r.Cookie(playerCookie); name == "" && err == nil {
+		name = c.Value
+	}
+	if name == "" {
+		name = s.player
+	}
+	if err := checkPlayerName(name); err != nil {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			writeError(w, http.StatusBadRequest, "invalid_player", "%v", err)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.playerLocked(name), true
+}
+
+// playerLocked returns a player, loading their progress the first time
+// they are seen. The caller holds s.mu.
+func (s *webServer) playerLocked(name string) *webPlayer {
+	pl := s.players[name]
+	if pl == nil {
+		if err := createProfile(name); err != nil {
+			log.Printf("Warning: progress of player '%s' will not be saved: %v", name, err)
+		}
+		pl = &webPlayer{
+			name:     name,
+			store:    openProgress(name),
+			sessions: make(map[string]*webSession),
+			flashes:  make(map[string]flash),
+		}
+		s.players[name] = pl
+	}
+	return pl
+}
+
+// switchPlayer sets the player the browser plays as.
+func (s *webServer) switchPlayer(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.FormValue("player"))
+	if err := checkPlayerName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: playerCookie, Value: name, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// slug returns the challenge named in the request path, or answers 404 when
+// it is not listed in config.json.
+func (s *webServer) slug(w http.ResponseWriter, r *http.Request) (string, bool) {
+	dir := r.PathValue("slug")
+	if !slices.Contains(s.config.Challenges, dir) {
+		http.NotFound(w, r)
+		return "", false
+	}
+	return dir, true
+}
+
+// state returns the web state of a player's challenge. The caller holds
+// s.mu.
+func (pl *webPlayer) state(dir string) string {
+	ws := pl.sessions[dir]
+	switch {
+	case ws == nil:
+		return webStopped
+	case ws.sess == nil:
+		return webStarting
+	case ws.sess.State == StateKeptAlive:
+		return webKept
+	}
+	return webRunning
+}
+
+// redirect sends the browser back to a challenge page after a form post.
+func redirect(w http.ResponseWriter, r *http.Request, dir string) {
+	http.Redirect(w, r, "/challenges/"+url.PathEscape(dir), http.StatusSeeOther)
+}
+
+func (s *webServer) render(w http.ResponseWriter, page string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.pages[page].ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Warning: could not render %s: %v", page, err)
+	}
+}
+
+// indexPage is the data of the challenge list.
+type indexPage struct {
+	Player     string
+	Challenges []challengeRow
+	Total      int
+	Scored     bool
+}
+
+type challengeRow struct {
+	Number    int
+	Slug      string
+	Name      string
+	State     string
+	Solved    bool
+	BestScore *int
+	Found     int // flags found in an unfinished attempt
+}
+
+func (s *webServer) index(w http.ResponseWriter, r *http.Request) {
+	pl, ok := s.requestPlayer(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	data := indexPage{Player: pl.name}
+	for i, dir := range s.config.Challenges {
+		row := challengeRow{Number: i + 1, Slug: dir, Name: dir, State: pl.state(dir)}
+		if challenge, err := loadChallenge(s.config.ChallengePath(dir)); err == nil && challenge.Name != "" {
+			row.Name = challenge.Name
+		}
+		if p := pl.store.Challenges[dir]; p != nil {
+			row.Solved, row.BestScore = p.Solved, p.BestScore
+			if p.Attempt != nil {
+				row.Found = len(p.Attempt.Found)
+			}
+		}
+		data.Challenges = append(data.Challenges, row)
+	}
+	data.Total, data.Scored = pl.store.totalScore()
+	s.mu.Unlock()
+	s.render(w, "index.html", data)
+}
+
+// challengePage is the data of a challenge page.
+type challengePage struct {
+	Player string
+	Slug   string
+	Name   string
+	State  string
+	Solved bool // solved in this or an earlier session
+	Flash  *flash
+
+	Preface   string
+	Postface  string
+	Endpoints []Endpoint
+
+	Elapsed   string
+	Limit     string
+	Remaining string
+
+	Found      int
+	Total      int
+	Objectives []string // found flags of multi-flag challenges
+	Hints      []string // revealed hints
+	HintsLeft  int
+	Penalty    int
+
+	ScoreEnabled bool
+	Score        int
+	Max          int
+}
+
+func (s *webServer) challengePage(w http.ResponseWriter, r *http.Request) {
+	dir, ok := s.slug(w, r)
+	if !ok {
+		return
+	}
+	pl, ok := s.requestPlayer(w, r)
+	if !ok {
+		return
+	}
+	challenge, err := loadChallenge(s.config.ChallengePath(dir))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	data := challengePage{
+		Player:  pl.name,
+		Slug:    dir,
+		Name:    challenge.Name,
+		State:   pl.state(dir),
+		Solved:  pl.store.solved(dir),
+		Preface: challenge.Preface,
+		Total:   max(len(challenge.Flags), 1),
+	}
+	if f, ok := pl.flashes[dir]; ok {
+		data.Flash = &f
+		delete(pl.flashes, dir)
+	}
+	if p := pl.store.Challenges[dir]; p != nil && p.Attempt != nil {
+		data.Found = len(p.Attempt.Found)
+	}
+	if ws := pl.sessions[dir]; ws != nil && ws.sess != nil {
+		inst, p, now := ws.sess.inst, ws.play, time.Now()
+		ws.active = now
+		challenge = inst.Challenge
+		data.Endpoints = endpointsFor(r, inst.Runtime.Endpoints(inst))
+		data.Postface = challenge.Postface
+		data.Elapsed = p.elapsed(now).Round(time.Second).String()
+		if p.limit > 0 {
+			data.Limit, data.Remaining = p.limit.String(), p.remaining(now).Round(time.Second).String()
+		}
+		data.Found = len(p.found)
+		for i, f := range challenge.Flags {
+			if !p.found[i] {
+				continue
+			}
+			if f.Label != "" {
+				data.Objectives = append(data.Objectives, f.Label)
+			} else {
+				data.Objectives = append(data.Objectives, fmt.Sprintf("Flag %d", i+1))
+			}
+		}
+		// A resumed attempt may have revealed hints the manifest no
+		// longer has.
+		revealed := min(p.score.Hints, len(challenge.Hints))
+		data.Hints = challenge.Hints[:revealed]
+		data.HintsLeft = len(challenge.Hints) - revealed
+		data.Penalty = challenge.Scoring.HintPenalty
+		data.ScoreEnabled, data.Score, data.Max = p.score.Enabled(), p.score.Total(), p.score.Max()
+	}
+	s.mu.Unlock()
+	s.render(w, "challenge.html", data)
+}
+
+// endpointsFor points the endpoints of a challenge at the host the browser
+// reached the server on, so players on other machines can use them.
+func endpointsFor(r *http.Request, endpoints []Endpoint) []Endpoint {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		return endpoints
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	out := make([]Endpoint, len(endpoints))
+	for i, ep := range endpoints {
+		ep.URL = strings.Replace(ep.URL, "127.0.0.1", host, 1)
+		out[i] = ep
+	}
+	return out
+}
+
+// Errors of the operations shared by the web UI and the API.
+var (
+	errBusy       = errors.New("challenge is already running")
+	errNotRunning = errors.New("challenge is not running")
+	errNoHints    = errors.New("challenge has no hints")
+	errHintsUsed  = errors.New("every hint was already revealed")
+)
+
+// launch registers a player's challenge as starting, for run to start it.
+func (s *webServer) launch(pl *webPlayer, dir string) (*webSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := pl.sessions[dir]; busy {
+		return nil, errBusy
+	}
+	ws := &webSession{}
+	pl.sessions[dir] = ws
+	s.starting.Add(1)
+	return ws, nil
+}
+
+// run starts a launched challenge and starts playing it.
+func (s *webServer) run(pl *webPlayer, dir string, ws *webSession) error {
+	defer s.starting.Done()
+	sess, err := startChallenge(s.ctx, s.cli, s.config, pl.store, dir, s.forceBuild, s.debug, true)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		delete(pl.sessions, dir)
+		pl.store.finish(dir, sess, time.Now())
+		pl.flashes[dir] = flash{Kind: "error", Lines: []string{fmt.Sprintf("Não foi possível iniciar o desafio: %v", err)}}
+		return err
+	}
+	ws.sess, ws.started, ws.active = sess, time.Now(), time.Now()
+	ws.play = newPlay(sess.inst, ws.started)
+	if deadline, _ := ws.play.deadline(ws.active); !deadline.IsZero() {
+		ws.timer = time.AfterFunc(time.Until(deadline), func() { s.expire(pl, dir, ws) })
+	}
+	if ws.play.resumed() {
+		pl.flashes[dir] = flash{Kind: "info", Lines: []string{"Retomando de onde você parou."}}
+	}
+	return nil
+}
+
+// expire ends a session that ran out of time: its time limit, its TTL, or
+// its idle timeout. When the player was active since the timer was set, the
+// timer is set again instead. Environments kept alive after a solve only
+// expire with their TTL or idle timeout.
+func (s *webServer) expire(pl *webPlayer, dir string, ws *webSession) {
+	s.mu.Lock()
+	if pl.sessions[dir] != ws || ws.sess.State != StateRunning && ws.sess.State != StateKeptAlive {
+		s.mu.Unlock()
+		return
+	}
+	deadline, why := ws.play.deadline(ws.active)
+	if wait := time.Until(deadline); wait > 0 {
+		ws.timer.Reset(wait)
+		s.mu.Unlock()
+		return
+	}
+	inst, outcome := ws.sess.inst, OutcomeExpired
+	if why == expiryLimit {
+		inst.event(Event{Type: eventTimeout})
+		pl.flashes[dir] = flash{Kind: "error", Lines: []string{fmt.Sprintf("⏰ Tempo esgotado! O limite de %s para este desafio acabou.", ws.play.limit)}}
+		outcome = OutcomeTimeout
+	} else {
+		inst.event(Event{Type: eventExpired, Result: why})
+		pl.flashes[dir] = flash{Kind: "error", Lines: []string{expiryMessage(inst, why)}}
+	}
+	s.mu.Unlock()
+	s.end(pl, dir, ws, outcome)
+}
+
+// end takes a session off the server and ends it. Solved sessions whose
+// environment was kept running are released.
+func (s *webServer) end(pl *webPlayer, dir string, ws *webSession, outcome Outcome) {
+	s.mu.Lock()
+	if pl.sessions[dir] != ws || ws.sess == nil {
+		s.mu.Unlock()
+		return
+	}
+	delete(pl.sessions, dir)
+	if ws.timer != nil {
+		ws.timer.Stop()
+	}
+	s.mu.Unlock()
+
+	if ws.sess.State == StateKeptAlive {
+		if _, err := ws.sess.advance(s.ctx, OutcomeRelease); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+	endChallenge(s.ctx, ws.sess, outcome)
+	s.mu.Lock()
+	pl.store.finish(dir, ws.sess, time.Now())
+	s.mu.Unlock()
+}
+
+// running returns the session of a challenge the player is playing, which
+// counts as player activity. The caller holds s.mu.
+func (pl *webPlayer) running(dir string) (*webSession, error) {
+	ws := pl.sessions[dir]
+	if ws == nil || ws.sess == nil || ws.sess.State != StateRunning {
+		return nil, errNotRunning
+	}
+	ws.active = time.Now()
+	return ws, nil
+}
+
+// submission is a checked flag and where it left the challenge.
+type submission struct {
+	verdict
+	Flag     FlagSpec // the flag found, for correct submissions
+	Found    int
+	Total    int
+	Solved   bool
+	Postface string
+	Score    *int
+
+	multiFlag bool
+}
+
+// submit checks a flag against a running challenge. Once every flag is
+// found, the session ends, or is kept alive for challenges that ask for it.
+// Validators run without s.mu held, since they can take seconds.
+func (s *webServer) submit(pl *webPlayer, dir, input string) (submission, error) {
+	s.mu.Lock()
+	ws, err := pl.running(dir)
+	s.mu.Unlock()
+	if err != nil {
+		return submission{}, err
+	}
+	ws.submitting.Lock()
+	defer ws.submitting.Unlock()
+
+	// stillRunning reports whether the session was not ended meanwhile.
+	stillRunning := func() bool {
+		return pl.sessions[dir] == ws && ws.sess.State == StateRunning
+	}
+	s.mu.Lock()
+	if !stillRunning() {
+		s.mu.Unlock()
+		return submission{}, errNotRunning
+	}
+	p, challenge := ws.play, ws.sess.inst.Challenge
+	if v, throttled := p.throttle(input, time.Now()); throttled {
+		s.mu.Unlock()
+		return submission{verdict: v, Found: len(p.found), Total: len(p.flags()), multiFlag: p.multiFlag()}, nil
+	}
+	found := maps.Clone(p.found)
+	s.mu.Unlock()
+
+	// Exec validators may be slow: other requests are served meanwhile,
+	// while further flags of this session wait their turn.
+	m := p.match(s.ctx, input, found)
+
+	s.mu.Lock()
+	if !stillRunning() {
+		s.mu.Unlock()
+		return submission{}, errNotRunning
+	}
+	sub := submission{verdict: p.apply(input, m, time.Now())}
+	if sub.Result == flagCorrect {
+		sub.Flag = p.flags()[sub.Index]
+	}
+	sub.Found, sub.Total, sub.Solved, sub.multiFlag = len(p.found), len(p.flags()), p.solved(), p.multiFlag()
+	if sub.Solved {
+		sub.Postface = challenge.Postface
+	}
+	if p.score.Enabled() {
+		total := p.score.Total()
+		sub.Score = &total
+	}
+
+	tearDown := sub.Solved
+	if sub.Solved && challenge.KeepRunningAfterSolve {
+		// Nothing is torn down: the environment stays up until the
+		// player stops it, for the challenges that build on it, or it
+		// expires.
+		endChallenge(s.ctx, ws.sess, OutcomeContinue)
+		pl.store.finish(dir, ws.sess, time.Now())
+		tearDown = false
+	}
+	s.mu.Unlock()
+
+	if tearDown {
+		s.end(pl, dir, ws, OutcomeSolved)
+	}
+	return sub, nil
+}
+
+// revealedHint is a hint revealed for a running challenge.
+type revealedHint struct {
+	Text    string
+	Number  int
+	Total   int
+	Penalty int
+}
+
+// hint reveals the next hint of a running challenge.
+func (s *webServer) hint(pl *webPlayer, dir string) (revealedHint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws, err := pl.running(dir)
+	if err != nil {
+		return revealedHint{}, err
+	}
+	hints := ws.sess.inst.Challenge.Hints
+	if len(hints) == 0 {
+		return revealedHint{}, errNoHints
+	}
+	text, penalty, ok := ws.play.hint(time.Now())
+	if !ok {
+		return revealedHint{}, errHintsUsed
+	}
+	return revealedHint{Text: text, Number: ws.play.score.Hints, Total: len(hints), Penalty: penalty}, nil
+}
+
+// formRequest returns the challenge and player of a form post.
+func (s *webServer) formRequest(w http.ResponseWriter, r *http.Request) (*webPlayer, string, bool) {
+	dir, ok := s.slug(w, r)
+	if !ok {
+		return nil, "", false
+	}
+	pl, ok := s.requestPlayer(w, r)
+	return pl, dir, ok
+}
+
+func (s *webServer) start(w http.ResponseWriter, r *http.Request) {
+	pl, dir, ok := s.formRequest(w, r)
+	if !ok {
+		return
+	}
+	if ws, err := s.launch(pl, dir); err == nil {
+		go s.run(pl, dir, ws)
+	}
+	redirect(w, r, dir)
+}
+
+func (s *webServer) stop(w http.ResponseWriter, r *http.Request) {
+	pl, dir, ok := s.formRequest(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	ws := pl.sessions[dir]
+	if ws != nil && ws.sess == nil {
+		pl.flashes[dir] = flash{Kind: "info", Lines: []string{"O desafio ainda está sendo iniciado."}}
+	}
+	s.mu.Unlock()
+	if ws != nil {
+		s.end(pl, dir, ws, OutcomeMenu)
+	}
+	redirect(w, r, dir)
+}
+
+// setFlash sets the message shown on the player's next view of a
+// challenge page.
+func (s *webServer) setFlash(pl *webPlayer, dir string, f flash) {
+	s.mu.Lock()
+	pl.flashes[dir] = f
+	s.mu.Unlock()
+}
+
+// notRunning is the flash shown when a form is posted for a challenge that
+// is not being played.
+var notRunning = flash{Kind: "error", Lines: []string{"O desafio não está em execução."}}
+
+func (s *webServer) submitFlag(w http.ResponseWriter, r *http.Request) {
+	pl, dir, ok := s.formRequest(w, r)
+	if !ok {
+		return
+	}
+	defer redirect(w, r, dir)
+	sub, err := s.submit(pl, dir, strings.TrimSpace(r.FormValue("flag")))
+	if err != nil {
+		s.setFlash(pl, dir, notRunning)
+		return
+	}
+
+	var f flash
+	switch sub.Result {
+	case flagThrottled:
+		f = flash{Kind: "error", Lines: []string{fmt.Sprintf("⏳ Muitas tentativas incorretas. Aguarde %s antes de tentar novamente.", max(sub.Wait.Round(time.Second), time.Second))}}
+	case flagDuplicate:
+		f = flash{Kind: "info", Lines: []string{"Flag já encontrada"}}
+	case flagWrong:
+		f = flash{Kind: "error", Lines: []string{"Flag incorreta. Tente novamente."}}
+		if sub.Feedback != "" {
+			f.Lines = append(f.Lines, sub.Feedback)
+		}
+	case flagLocked:
+		f = flash{Kind: "error", Lines: []string{"Esta flag pertence a um objetivo posterior. Complete os objetivos anteriores primeiro."}}
+	case flagCorrect:
+		f = flash{Kind: "ok"}
+		switch {
+		case sub.multiFlag:
+			f.Lines = append(f.Lines, fmt.Sprintf("✅ %d/%d: %s", sub.Found, sub.Total, sub.Flag.title(sub.Points)))
+		case sub.Points != 0:
+			f.Lines = append(f.Lines, fmt.Sprintf("✅ Correto! Muito bem. (+%d)", sub.Points))
+		default:
+			f.Lines = append(f.Lines, "✅ Correto! Muito bem.")
+		}
+		if sub.Flag.Message != "" {
+			f.Lines = append(f.Lines, sub.Flag.Message)
+		}
+		if sub.Postface != "" {
+			f.Lines = append(f.Lines, sub.Postface)
+		}
+	}
+	s.setFlash(pl, dir, f)
+}
+
+func (s *webServer) revealHint(w http.ResponseWriter, r *http.Request) {
+	pl, dir, ok := s.formRequest(w, r)
+	if !ok {
+		return
+	}
+	defer redirect(w, r, dir)
+	h, err := s.hint(pl, dir)
+	switch {
+	case errors.Is(err, errNotRunning):
+		s.setFlash(pl, dir, notRunning)
+	case errors.Is(err, errNoHints):
+		s.setFlash(pl, dir, flash{Kind: "info", Lines: []string{"Nenhuma dica disponível para este desafio."}})
+	case errors.Is(err, errHintsUsed):
+		s.setFlash(pl, dir, flash{Kind: "info", Lines: []string{"Não há mais dicas disponíveis."}})
+	case h.Penalty != 0:
+		s.setFlash(pl, dir, flash{Kind: "info", Lines: []string{fmt.Sprintf("Dica %d/%d revelada (-%d pontos).", h.Number, h.Total, h.Penalty)}})
+	default:
+		s.setFlash(pl, dir, flash{Kind: "info", Lines: []string{fmt.Sprintf("Dica %d/%d revelada.", h.Number, h.Total)}})
+	}
+}
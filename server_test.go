@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubmitThrottlesParallelMisses(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	// A slow validator, so that parallel submissions overlap.
+	script := "#!/bin/sh\nsleep 0.2\n[ \"$1\" = 'WSS{ok}' ]\n"
+	if err := os.WriteFile(filepath.Join(dir, "check.sh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inst := &ChallengeInstance{Path: dir, DirName: "test", SessionID: "test"}
+	inst.Challenge.Flag = FlagSpec{Exec: "./check.sh", Match: matchExec}
+	inst.Challenge.Submissions = SubmissionPolicy{FreeAttempts: 1, Backoff: Duration(time.Minute)}
+	sess := newSession(inst)
+	if _, err := sess.advance(context.Background(), OutcomeStarted); err != nil {
+		t.Fatal(err)
+	}
+	ws := &webSession{sess: sess, play: newPlay(inst, time.Now())}
+	pl := &webPlayer{name: "test", sessions: map[string]*webSession{"test": ws}}
+	s := &webServer{ctx: context.Background()}
+
+	const n = 5
+	results := make(chan string, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub, err := s.submit(pl, "test", "WSS{wrong}")
+			if err != nil {
+				t.Error(err)
+			}
+			results <- sub.Result
+		}()
+	}
+	wg.Wait()
+	close(results)
+	count := make(map[string]int)
+	for r := range results {
+		count[r]++
+	}
+	if count[flagWrong] != 1 || count[flagThrottled] != n-1 {
+		t.Errorf("results = %v, want 1 wrong and %d throttled", count, n-1)
+	}
+}
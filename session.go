@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// printBanner prints a block of challenge text between separator lines.
+func printBanner(text string) {
+	fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println(text)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+}
+
+// printEndpoints shows the player where a running challenge can be reached.
+func printEndpoints(endpoints []Endpoint) {
+	fmt.Println("   Você pode interagir com ele em:")
+	for _, ep := range endpoints {
+		if ep.Label != "" {
+			fmt.Printf("   - %s: %s\n", ep.Label, ep.URL)
+		} else {
+			fmt.Printf("   - %s\n", ep.URL)
+		}
+	}
+}
+
+// stdin is shared by the main menu and the challenge prompt so that input
+// buffered by one of them is not lost to the other.
+var stdin = bufio.NewReader(os.Stdin)
+
+// stdinLines carries the lines read from stdin by a single goroutine, so a
+// prompt can stop waiting, e.g. when time runs out, and the line typed next
+// still goes to the following prompt. It is closed at end of input.
+var (
+	stdinLines chan string
+	stdinOnce  sync.Once
+)
+
+func inputLines() <-chan string {
+	stdinOnce.Do(func() {
+		stdinLines = make(chan string)
+		go func() {
+			defer close(stdinLines)
+			for {
+				input, err := stdin.ReadString('\n')
+				if input != "" || err == nil {
+					stdinLines <- strings.TrimSpace(input)
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	})
+	return stdinLines
+}
+
+// readLine reads a line from stdin without surrounding whitespace. It
+// returns io.EOF once stdin is closed, for example by Ctrl-D.
+func readLine() (string, error) {
+	input, ok := <-inputLines()
+	if !ok {
+		return "", io.EOF
+	}
+	return input, nil
+}
+
+// readLineUntil is readLine, giving up when expired fires; ok is then false.
+// A nil channel never fires.
+func readLineUntil(expired <-chan time.Time) (input string, ok bool, err error) {
+	select {
+	case input, open := <-inputLines():
+		if !open {
+			return "", true, io.EOF
+		}
+		return input, true, nil
+	case <-expired:
+		return "", false, nil
+	}
+}
+
+// interact runs the flag/hint prompt for a started challenge and returns
+// how the player left it. Solving a challenge that declares
+// keep_running_after_solve returns OutcomeContinue instead of asking where
+// to go next.
+func interact(ctx context.Context, inst *ChallengeInstance) Outcome {
+	challenge := inst.Challenge
+	p := newPlay(inst, time.Now())
+	score := p.score
+	total := len(p.flags())
+
+	// showScore prints the running score of challenges worth points.
+	showScore := func() {
+		if score.Enabled() {
+			fmt.Printf("   Pontuação: %d/%d\n", score.Total(), score.Max())
+		}
+	}
+
+	// prompt reads the next command, giving up when the session runs out
+	// of time; why then says what ran out. The time limit keeps running
+	// across resumed attempts, so leaving for the menu does not buy more
+	// time, while any command resets the idle timeout. err is io.EOF once
+	// stdin is closed.
+	prompt := func() (input, why string, ok bool, err error) {
+		deadline, why := p.deadline(time.Now())
+		if deadline.IsZero() {
+			input, err = readLine()
+			return input, "", true, err
+		}
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		input, ok, err = readLineUntil(timer.C)
+		return input, why, ok, err
+	}
+
+	if p.resumed() {
+		fmt.Printf("Retomando de onde você parou: %d/%d flag(s) encontrada(s), %d dica(s) usada(s).\n", len(p.found), total, score.Hints)
+		showScore()
+	}
+	if p.limit > 0 {
+		fmt.Printf("⏱  Tempo limite: %s. Restam %s. (Digite 'status' para ver o tempo)\n", p.limit, p.remaining(time.Now()).Round(time.Second))
+	}
+
+	for {
+		fmt.Print("Digite a flag > ")
+		input, why, ok, err := prompt()
+		if err != nil {
+			// Nobody is left to type: leave as if the player quit.
+			fmt.Println()
+			inst.event(Event{Type: eventQuit})
+			return OutcomeQuit
+		}
+		if !ok && why == expiryLimit {
+			fmt.Printf("\n⏰ Tempo esgotado! O limite de %s para este desafio acabou.\n", p.limit)
+			showScore()
+			inst.event(Event{Type: eventTimeout})
+			return OutcomeTimeout
+		}
+		if !ok {
+			fmt.Printf("\n%s\n", expiryMessage(inst, why))
+			inst.event(Event{Type: eventExpired, Result: why})
+			return OutcomeExpired
+		}
+
+		// Check for special commands first
+		switch strings.ToLower(input) {
+		case "hint":
+			if len(challenge.Hints) == 0 {
+				fmt.Println("Nenhuma dica disponível para este desafio.")
+			} else if hint, penalty, ok := p.hint(time.Now()); !ok {
+				fmt.Println("Não há mais dicas disponíveis.")
+			} else if penalty != 0 {
+				fmt.Printf("Dica %d/%d (-%d pontos): %s\n", score.Hints, len(challenge.Hints), penalty, hint)
+			} else {
+				fmt.Printf("Dica %d/%d: %s\n", score.Hints, len(challenge.Hints), hint)
+			}
+			continue
+		case "status":
+			elapsed := p.elapsed(time.Now()).Round(time.Second)
+			fmt.Printf("⏱  Tempo decorrido: %s\n", elapsed)
+			if p.limit > 0 {
+				fmt.Printf("   Tempo restante: %s de %s\n", max(p.limit-elapsed, 0), p.limit)
+			}
+			fmt.Printf("   Flags encontradas: %d/%d\n", len(p.found), total)
+			if len(challenge.Hints) > 0 {
+				fmt.Printf("   Dicas usadas: %d/%d\n", score.Hints, len(challenge.Hints))
+			}
+			showScore()
+			continue
+		case "menu":
+			return OutcomeMenu
+		case "quit", "exit":
+			inst.event(Event{Type: eventQuit})
+			return OutcomeQuit
+		}
+
+		// Flag validation
+		v := p.submit(ctx, input, time.Now())
+		switch v.Result {
+		case flagThrottled:
+			fmt.Printf("⏳ Muitas tentativas incorretas. Aguarde %s antes de tentar novamente.\n", max(v.Wait.Round(time.Second), time.Second))
+			continue
+		case flagDuplicate:
+			fmt.Println("Flag já encontrada")
+			continue
+		case flagWrong:
+			fmt.Println("Flag incorreta. Tente novamente. (Digite 'hint' para uma dica, 'menu' para voltar ao menu, ou 'quit' para sair)")
+			if v.Feedback != "" {
+				fmt.Println(v.Feedback)
+			}
+			continue
+		case flagLocked:
+			fmt.Println("Esta flag pertence a um objetivo posterior. Complete os objetivos anteriores primeiro.")
+			continue
+		}
+
+		found := p.flags()[v.Index]
+		switch {
+		case p.multiFlag():
+			fmt.Printf("\n✅ %d/%d: %s\n", len(p.found), total, found.title(v.Points))
+		case v.Points != 0:
+			fmt.Printf("\n✅ Correto! Muito bem. (+%d)\n", v.Points)
+		default:
+			fmt.Println("\n✅ Correto! Muito bem.")
+		}
+		if found.Message != "" {
+			fmt.Println(found.Message)
+		}
+		showScore()
+		if !p.solved() {
+			continue
+		}
+
+		if challenge.Postface != "" {
+			printBanner(challenge.Postface)
+		}
+		if challenge.KeepRunningAfterSolve {
+			return OutcomeContinue
+		}
+		if p.multiFlag() {
+			return OutcomeSolved
+		}
+		fmt.Println("\nDigite 'next' para ir direto ao próximo desafio, ou pressione Enter para voltar ao menu...")
+		if input, _ := readLine(); strings.EqualFold(input, "next") {
+			return OutcomeNext
+		}
+		return OutcomeSolved
+	}
+}
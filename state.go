@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Outcome is an event that moves a challenge session from one state to
+// another: the environment coming up, or the way the player left the prompt.
+type Outcome int
+
+const (
+	// OutcomeStarted means the challenge environment is up.
+	OutcomeStarted Outcome = iota
+	// OutcomeFail means the challenge could not be prepared or started.
+	OutcomeFail
+	// OutcomeSolved means every flag was found; the player goes back to the menu.
+	OutcomeSolved
+	// OutcomeNext means the challenge was solved and the player asked for the next one.
+	OutcomeNext
+	// OutcomeContinue means the challenge was solved and must stay up while
+	// the next one starts.
+	OutcomeContinue
+	// OutcomeMenu means the player left an unsolved challenge for the menu.
+	OutcomeMenu
+	// OutcomeQuit means the player left the platform.
+	OutcomeQuit
+	// OutcomeRelease means a chain of challenges ended and an environment
+	// kept alive along the way can go.
+	OutcomeRelease
+	// OutcomeTimeout means the challenge's time limit ran out.
+	OutcomeTimeout
+	// OutcomeExpired means the instance reached its TTL or the player left
+	// it idle for too long.
+	OutcomeExpired
+)
+
+var outcomeNames = [...]string{
+	OutcomeStarted:  "started",
+	OutcomeFail:     "fail",
+	OutcomeSolved:   "solved",
+	OutcomeNext:     "next",
+	OutcomeContinue: "continue",
+	OutcomeMenu:     "menu",
+	OutcomeQuit:     "quit",
+	OutcomeRelease:  "release",
+	OutcomeTimeout:  "timeout",
+	OutcomeExpired:  "expired",
+}
+
+func (o Outcome) String() string {
+	if o < 0 || int(o) >= len(outcomeNames) {
+		return fmt.Sprintf("Outcome(%d)", int(o))
+	}
+	return outcomeNames[o]
+}
+
+// SessionState is the lifecycle state of a single challenge session.
+type SessionState int
+
+const (
+	StateStarting SessionState = iota
+	StateRunning
+	StateSolved
+	StateAbandoned
+	StateFailed
+	StateKeptAlive
+	StateTimedOut
+	StateExpired
+)
+
+var stateNames = [...]string{
+	StateStarting:  "Starting",
+	StateRunning:   "Running",
+	StateSolved:    "Solved",
+	StateAbandoned: "Abandoned",
+	StateFailed:    "Failed",
+	StateKeptAlive: "KeptAlive",
+	StateTimedOut:  "TimedOut",
+	StateExpired:   "Expired",
+}
+
+func (s SessionState) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return fmt.Sprintf("SessionState(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+// Terminal reports whether the session is over. A kept-alive session is
+// over for the player but still owns a running environment.
+func (s SessionState) Terminal() bool {
+	return s != StateStarting && s != StateRunning
+}
+
+// Cleanup is what happens to the challenge environment on a transition.
+type Cleanup int
+
+const (
+	// CleanupNone leaves the environment as it is.
+	CleanupNone Cleanup = iota
+	// CleanupStop tears the environment down, keeping cached images.
+	CleanupStop
+)
+
+// Navigation is where the player goes once a session is over.
+type Navigation int
+
+const (
+	// NavStay keeps the player in the current session.
+	NavStay Navigation = iota
+	// NavMenu goes back to the main menu.
+	NavMenu
+	// NavNext starts the next challenge.
+	NavNext
+	// NavNextSilent starts the next challenge without its introduction.
+	NavNextSilent
+	// NavQuit leaves the platform.
+	NavQuit
+)
+
+// Transition describes a single edge of the session state machine.
+type Transition struct {
+	From    SessionState
+	Outcome Outcome
+	To      SessionState
+	Cleanup Cleanup
+	Nav     Navigation
+}
+
+// transitions is the complete session state machine. Any pair of state and
+// outcome missing from this table is an invalid transition.
+var transitions = []Transition{
+	{StateStarting, OutcomeStarted, StateRunning, CleanupNone, NavStay},
+	{StateStarting, OutcomeFail, StateFailed, CleanupStop, NavMenu},
+	{StateRunning, OutcomeSolved, StateSolved, CleanupStop, NavMenu},
+	{StateRunning, OutcomeNext, StateSolved, CleanupStop, NavNext},
+	{StateRunning, OutcomeContinue, StateKeptAlive, CleanupNone, NavNextSilent},
+	{StateRunning, OutcomeMenu, StateAbandoned, CleanupStop, NavMenu},
+	{StateRunning, OutcomeQuit, StateAbandoned, CleanupStop, NavQuit},
+	{StateRunning, OutcomeTimeout, StateTimedOut, CleanupStop, NavMenu},
+	{StateRunning, OutcomeExpired, StateExpired, CleanupStop, NavMenu},
+	{StateKeptAlive, OutcomeRelease, StateSolved, CleanupStop, NavStay},
+}
+
+// nextTransition looks up the transition taken from a state on an outcome.
+func nextTransition(from SessionState, outcome Outcome) (Transition, error) {
+	for _, t := range transitions {
+		if t.From == from && t.Outcome == outcome {
+			return t, nil
+		}
+	}
+	return Transition{}, fmt.Errorf("invalid session transition from %s on %s", from, outcome)
+}
+
+// Session tracks the state of one challenge run and applies the cleanup
+// attached to each transition.
+type Session struct {
+	State SessionState
+	Last  Transition
+	inst  *ChallengeInstance
+}
+
+func newSession(inst *ChallengeInstance) *Session {
+	return &Session{State: StateStarting, inst: inst}
+}
+
+// advance moves the session along on an outcome and runs the cleanup the
+// transition calls for. Invalid transitions leave the session unchanged.
+func (s *Session) advance(ctx context.Context, outcome Outcome) (Transition, error) {
+	t, err := nextTransition(s.State, outcome)
+	if err != nil {
+		return t, err
+	}
+	s.State = t.To
+	s.Last = t
+
+	if s.inst == nil || t.Cleanup != CleanupStop {
+		return t, nil
+	}
+	switch outcome {
+	case OutcomeFail:
+		// Nothing was played; tear down quietly.
+	case OutcomeRelease:
+		fmt.Printf("\nEncerrando o desafio '%s'...\n", s.inst.Challenge.Name)
+	default:
+		fmt.Println("\nEncerrando o desafio atual...")
+	}
+	if err := s.inst.Runtime.Stop(ctx, s.inst); err != nil {
+		log.Printf("Warning: could not stop challenge %s: %v", s.inst.DirName, err)
+	}
+	s.inst.removeFlagFile()
+	s.inst.releasePorts()
+	s.inst.dropLease()
+	return t, nil
+}
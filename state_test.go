@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNextTransition(t *testing.T) {
+	tests := []struct {
+		from    SessionState
+		outcome Outcome
+		to      SessionState
+		cleanup Cleanup
+		nav     Navigation
+	}{
+		{StateStarting, OutcomeStarted, StateRunning, CleanupNone, NavStay},
+		{StateStarting, OutcomeFail, StateFailed, CleanupStop, NavMenu},
+		{StateRunning, OutcomeSolved, StateSolved, CleanupStop, NavMenu},
+		{StateRunning, OutcomeNext, StateSolved, CleanupStop, NavNext},
+		{StateRunning, OutcomeContinue, StateKeptAlive, CleanupNone, NavNextSilent},
+		{StateRunning, OutcomeMenu, StateAbandoned, CleanupStop, NavMenu},
+		{StateRunning, OutcomeQuit, StateAbandoned, CleanupStop, NavQuit},
+		{StateRunning, OutcomeTimeout, StateTimedOut, CleanupStop, NavMenu},
+		{StateRunning, OutcomeExpired, StateExpired, CleanupStop, NavMenu},
+		{StateKeptAlive, OutcomeRelease, StateSolved, CleanupStop, NavStay},
+	}
+	defined := make(map[[2]int]bool)
+	for _, tt := range tests {
+		defined[[2]int{int(tt.from), int(tt.outcome)}] = true
+		got, err := nextTransition(tt.from, tt.outcome)
+		if err != nil {
+			t.Errorf("%s on %s: %v", tt.from, tt.outcome, err)
+			continue
+		}
+		if got.To != tt.to || got.Cleanup != tt.cleanup || got.Nav != tt.nav {
+			t.Errorf("%s on %s = (%s, %d, %d), want (%s, %d, %d)", tt.from, tt.outcome, got.To, got.Cleanup, got.Nav, tt.to, tt.cleanup, tt.nav)
+		}
+	}
+	if len(tests) != len(transitions) {
+		t.Errorf("tested %d transitions, table has %d", len(tests), len(transitions))
+	}
+
+	// Every other pair of state and outcome is invalid.
+	for from := range SessionState(len(stateNames)) {
+		for outcome := range Outcome(len(outcomeNames)) {
+			if defined[[2]int{int(from), int(outcome)}] {
+				continue
+			}
+			if _, err := nextTransition(from, outcome); err == nil {
+				t.Errorf("%s on %s: want error for undefined transition", from, outcome)
+			}
+		}
+	}
+}
+
+func TestSessionAdvanceRejectsInvalid(t *testing.T) {
+	s := newSession(nil)
+	if _, err := s.advance(context.Background(), OutcomeSolved); err == nil {
+		t.Fatal("advance from Starting on solved: want error")
+	}
+	if s.State != StateStarting {
+		t.Errorf("state after invalid transition = %s, want %s", s.State, StateStarting)
+	}
+	if _, err := s.advance(context.Background(), OutcomeStarted); err != nil {
+		t.Fatal(err)
+	}
+	if s.State != StateRunning {
+		t.Errorf("state = %s, want %s", s.State, StateRunning)
+	}
+}
+
+// stopCounter is a runtime that only counts how often it was stopped.
+type stopCounter struct{ stops int }
+
+func (*stopCounter) Name() string                                      { return "test" }
+func (*stopCounter) Detect(string) bool                                { return false }
+func (*stopCounter) Prepare(context.Context, *ChallengeInstance) error { return nil }
+func (*stopCounter) Start(context.Context, *ChallengeInstance) error   { return nil }
+func (*stopCounter) Endpoints(*ChallengeInstance) []Endpoint           { return nil }
+func (rt *stopCounter) Stop(context.Context, *ChallengeInstance) error { rt.stops++; return nil }
+
+func TestSessionAdvanceStops(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tests := []struct {
+		outcomes []Outcome
+		stops    int
+	}{
+		{[]Outcome{OutcomeFail}, 1},
+		{[]Outcome{OutcomeStarted}, 0},
+		{[]Outcome{OutcomeStarted, OutcomeMenu}, 1},
+		{[]Outcome{OutcomeStarted, OutcomeContinue}, 0},
+		{[]Outcome{OutcomeStarted, OutcomeContinue, OutcomeRelease}, 1},
+	}
+	for _, tt := range tests {
+		rt := &stopCounter{}
+		s := newSession(&ChallengeInstance{Runtime: rt, SessionID: "test"})
+		for _, outcome := range tt.outcomes {
+			if _, err := s.advance(context.Background(), outcome); err != nil {
+				t.Fatalf("%v: %v", tt.outcomes, err)
+			}
+		}
+		if rt.stops != tt.stops {
+			t.Errorf("%v: stopped %d times, want %d", tt.outcomes, rt.stops, tt.stops)
+		}
+	}
+}
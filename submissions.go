@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SubmissionPolicy tunes how the prompt reacts to wrong flags: throttling
+// after repeated misses and hints that an answer is close.
+type SubmissionPolicy struct {
+	// FreeAttempts is how many wrong flags can be sent before throttling
+	// starts. Zero disables throttling.
+	FreeAttempts int `json:"free_attempts"`
+	// Backoff is the wait after the first throttled miss; it doubles with
+	// each further miss, up to MaxBackoff.
+	Backoff    Duration `json:"backoff"`
+	MaxBackoff Duration `json:"max_backoff"`
+
+	// Format is the shape every flag has, with "..." standing for the
+	// variable part, e.g. "WSS{...}". Answers of another shape get a
+	// warning.
+	Format string `json:"format"`
+	// NearMiss is the largest edit distance from a plaintext flag at which
+	// the player is told the answer is close. Zero disables it.
+	NearMiss int `json:"near_miss"`
+}
+
+// Default throttling delays, used when a policy enables throttling without
+// setting them.
+const (
+	defaultBackoff    = 2 * time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// Duration is a time.Duration written in challenge.json as a string such
+// as "2s" or "1m30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"2s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v < 0 {
+		return fmt.Errorf("duration %s is negative", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// throttle enforces a SubmissionPolicy's backoff over one session.
+type throttle struct {
+	policy SubmissionPolicy
+	misses int
+	until  time.Time
+}
+
+// wait returns how long the player must wait before the next submission.
+func (t *throttle) wait(now time.Time) time.Duration {
+	if now.After(t.until) {
+		return 0
+	}
+	return t.until.Sub(now)
+}
+
+// miss records a wrong submission, starting or extending the backoff once
+// the free attempts are used up.
+func (t *throttle) miss(now time.Time) {
+	t.misses++
+	if t.policy.FreeAttempts == 0 || t.misses < t.policy.FreeAttempts {
+		return
+	}
+	delay := time.Duration(t.policy.Backoff)
+	if delay == 0 {
+		delay = defaultBackoff
+	}
+	limit := time.Duration(t.policy.MaxBackoff)
+	if limit == 0 {
+		limit = defaultMaxBackoff
+	}
+	for i := t.policy.FreeAttempts; i < t.misses && delay < limit; i++ {
+		delay *= 2
+	}
+	t.until = now.Add(min(delay, limit))
+}
+
+// hit records a correct submission, which clears the backoff.
+func (t *throttle) hit() {
+	t.misses, t.until = 0, time.Time{}
+}
+
+// feedback explains a wrong answer beyond "incorrect", or returns "" when
+// there is nothing to add. candidates are the flags still to be found.
+func (p SubmissionPolicy) feedback(input string, candidates []FlagSpec) string {
+	if p.Format != "" && !matchesFormat(p.Format, input) {
+		return fmt.Sprintf("⚠️  A resposta não está no formato das flags deste desafio: %s", p.Format)
+	}
+	if p.NearMiss > 0 {
+		for _, f := range candidates {
+			if f.Plaintext() && editDistance(f.fold(input), f.fold(f.Value)) <= p.NearMiss {
+				return "🔥 Quase! Sua resposta está muito próxima de uma flag válida."
+			}
+		}
+	}
+	return ""
+}
+
+// matchesFormat reports whether input has the shape of format, where "..."
+// stands for any non-empty text. Case is ignored.
+func matchesFormat(format, input string) bool {
+	prefix, suffix, found := strings.Cut(strings.ToLower(format), "...")
+	input = strings.ToLower(input)
+	if !found {
+		return input == prefix
+	}
+	return len(input) > len(prefix)+len(suffix) && strings.HasPrefix(input, prefix) && strings.HasSuffix(input, suffix)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottleBackoff(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		policy SubmissionPolicy
+		misses int
+		want   time.Duration
+	}{
+		{"disabled", SubmissionPolicy{}, 10, 0},
+		{"free attempts left", SubmissionPolicy{FreeAttempts: 3}, 2, 0},
+		{"first throttled miss", SubmissionPolicy{FreeAttempts: 3}, 3, defaultBackoff},
+		{"doubles", SubmissionPolicy{FreeAttempts: 3}, 5, 4 * defaultBackoff},
+		{"default cap", SubmissionPolicy{FreeAttempts: 1}, 20, defaultMaxBackoff},
+		{"custom backoff", SubmissionPolicy{FreeAttempts: 1, Backoff: Duration(time.Second)}, 3, 4 * time.Second},
+		{"custom cap", SubmissionPolicy{FreeAttempts: 1, Backoff: Duration(time.Second), MaxBackoff: Duration(5 * time.Second)}, 10, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		th := throttle{policy: tt.policy}
+		for range tt.misses {
+			th.miss(now)
+		}
+		if got := th.wait(now); got != tt.want {
+			t.Errorf("%s: wait after %d misses = %s, want %s", tt.name, tt.misses, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleWaitRunsOut(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	th := throttle{policy: SubmissionPolicy{FreeAttempts: 1, Backoff: Duration(10 * time.Second)}}
+	th.miss(now)
+	if got := th.wait(now.Add(4 * time.Second)); got != 6*time.Second {
+		t.Errorf("wait 4s later = %s, want 6s", got)
+	}
+	if got := th.wait(now.Add(11 * time.Second)); got != 0 {
+		t.Errorf("wait after the backoff = %s, want 0", got)
+	}
+	th.miss(now)
+	th.hit()
+	if got := th.wait(now); got != 0 || th.misses != 0 {
+		t.Errorf("after a hit: wait = %s, misses = %d, want 0 and 0", got, th.misses)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"WSS{flag}", "WSS{flag}", 0},
+		{"WSS{flag}", "WSS{flog}", 1},
+		{"WSS{flag}", "WSS{fla}", 1},
+		{"WSS{flag}", "WSS{flags}", 1},
+		{"kitten", "sitting", 3},
+		{"ção", "cao", 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSubmissionFeedback(t *testing.T) {
+	flags := []FlagSpec{{Value: "WSS{near_miss}"}}
+	hashed, err := hashFlag(flags[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		policy     SubmissionPolicy
+		input      string
+		candidates []FlagSpec
+		want       bool
+	}{
+		{"nothing enabled", SubmissionPolicy{}, "WSS{near_mis}", flags, false},
+		{"wrong format", SubmissionPolicy{Format: "WSS{...}"}, "flag", flags, true},
+		{"empty variable part", SubmissionPolicy{Format: "WSS{...}"}, "WSS{}", flags, true},
+		{"right format", SubmissionPolicy{Format: "WSS{...}"}, "wss{x}", flags, false},
+		{"near miss", SubmissionPolicy{NearMiss: 2}, "WSS{near_mis}", flags, true},
+		{"near miss ignores case", SubmissionPolicy{NearMiss: 1}, "wss{NEAR_MISS}!", flags, true},
+		{"too far", SubmissionPolicy{NearMiss: 2}, "WSS{far}", flags, false},
+		{"hashed flags give no hint", SubmissionPolicy{NearMiss: 2}, "WSS{near_mis}", []FlagSpec{hashed}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.feedback(tt.input, tt.candidates) != ""; got != tt.want {
+			t.Errorf("%s: feedback(%q) given = %v, want %v", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
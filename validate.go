@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+//go:embed schema/*.schema.json
+var schemaFS embed.FS
+
+// Schemas for the files authors write, compiled once on first use.
+var (
+	challengeSchema = mustCompileSchema("challenge.schema.json")
+	configSchema    = mustCompileSchema("config.schema.json")
+)
+
+func mustCompileSchema(name string) *jsonschema.Schema {
+	data, err := schemaFS.ReadFile("schema/" + name)
+	if err != nil {
+		panic(err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		panic(fmt.Sprintf("schema %s: %v", name, err))
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(name, doc); err != nil {
+		panic(fmt.Sprintf("schema %s: %v", name, err))
+	}
+	return c.MustCompile(name)
+}
+
+// decodeStrict parses JSON into v, rejecting fields v does not declare.
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after the top-level value")
+	}
+	return nil
+}
+
+// Problem is a single issue found while validating a challenge set.
+// Warnings point at likely mistakes that do not stop a challenge from running.
+type Problem struct {
+	File    string
+	Field   string
+	Message string
+	Warning bool
+}
+
+func (p Problem) String() string {
+	location := p.File
+	if p.Field != "" {
+		location += ": " + p.Field
+	}
+	if p.Warning {
+		return fmt.Sprintf("%s: warning: %s", location, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", location, p.Message)
+}
+
+// validator collects problems across all the files of a challenge set.
+type validator struct {
+	root     string
+	problems []Problem
+}
+
+func (v *validator) add(file, field, format string, args ...any) {
+	v.report(false, file, field, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) warn(file, field, format string, args ...any) {
+	v.report(true, file, field, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) report(warning bool, file, field, message string) {
+	rel, err := filepath.Rel(v.root, file)
+	if err != nil {
+		rel = file
+	}
+	v.problems = append(v.problems, Problem{File: rel, Field: field, Message: message, Warning: warning})
+}
+
+// checkJSON validates a file against a schema and decodes it strictly into
+// dst. It reports whether the file could be used.
+func (v *validator) checkJSON(file string, schema *jsonschema.Schema, dst any) bool {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		v.add(file, "", "could not read file: %v", err)
+		return false
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		v.add(file, "", "invalid JSON: %v", err)
+		return false
+	}
+
+	var verr *jsonschema.ValidationError
+	if err := schema.Validate(doc); errors.As(err, &verr) {
+		printer := message.NewPrinter(language.English)
+		for _, leaf := range validationLeaves(verr) {
+			v.add(file, strings.Join(leaf.InstanceLocation, "."), "%s", leaf.ErrorKind.LocalizedString(printer))
+		}
+		return false
+	} else if err != nil {
+		v.add(file, "", "%v", err)
+		return false
+	}
+
+	// The schema and the Go types should agree; report it if they do not.
+	if err := decodeStrict(data, dst); err != nil {
+		v.add(file, "", "%v", err)
+		return false
+	}
+	return true
+}
+
+// validationLeaves flattens a validation error tree into its most specific
+// causes. When a value fails every branch of a oneOf or anyOf, the branches it
+// does not even have the type of are left out.
+func validationLeaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	causes := err.Causes
+	switch err.ErrorKind.(type) {
+	case *kind.OneOf, *kind.AnyOf:
+		causes = slices.DeleteFunc(slices.Clone(causes), func(c *jsonschema.ValidationError) bool {
+			_, isType := c.ErrorKind.(*kind.Type)
+			return isType && len(c.Causes) == 0
+		})
+		if len(causes) == 0 {
+			causes = err.Causes
+		}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range causes {
+		leaves = append(leaves, validationLeaves(cause)...)
+	}
+	return leaves
+}
+
+// validateRoot checks config.json and every challenge directory under root
+// and returns all the problems found.
+func validateRoot(root string) []Problem {
+	v := &validator{root: root}
+	configPath := filepath.Join(root, "config.json")
+	config := Config{Root: root}
+	if !v.checkJSON(configPath, configSchema, &config) {
+		return v.problems
+	}
+
+	for i, dirName := range config.Challenges {
+		field := fmt.Sprintf("challenges.%d", i)
+		challengePath := config.ChallengePath(dirName)
+		if info, err := os.Stat(challengePath); err != nil || !info.IsDir() {
+			v.add(configPath, field, "challenge directory '%s' does not exist", dirName)
+			continue
+		}
+		v.validateChallenge(config, challengePath)
+	}
+
+	// Directories that look like challenges but are not played.
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		v.add(root, "", "could not list directory: %v", err)
+		return v.problems
+	}
+	for _, e := range entries {
+		if e.IsDir() && fileIn(filepath.Join(root, e.Name()), "challenge.json") && !slices.Contains(config.Challenges, e.Name()) {
+			v.add(configPath, "challenges", "challenge directory '%s' is not listed", e.Name())
+		}
+	}
+	return v.problems
+}
+
+// validateChallenge checks a single challenge directory.
+func (v *validator) validateChallenge(config Config, challengePath string) {
+	manifestPath := filepath.Join(challengePath, "challenge.json")
+	var challenge Challenge
+	valid := v.checkJSON(manifestPath, challengeSchema, &challenge)
+	if valid {
+		if challenge.Next != "" && !slices.Contains(config.Challenges, challenge.Next) {
+			v.add(manifestPath, "next", "challenge '%s' is not listed in config.json", challenge.Next)
+		}
+		if challenge.DynamicFlag != nil {
+			v.validateDynamicFlag(manifestPath, challenge)
+		}
+		ids := make(map[string]bool)
+		for i, f := range challenge.Flags {
+			if f.ID != "" && ids[f.ID] {
+				v.add(manifestPath, fmt.Sprintf("flags.%d.id", i), "duplicate flag id '%s'", f.ID)
+			}
+			ids[f.ID] = true
+		}
+	}
+
+	rt := detectRuntime(challengePath)
+	switch rt.(type) {
+	case nil:
+		v.add(challengePath, "", "no Dockerfile or docker-compose.yml found")
+	case composeRuntime:
+		cf, err := loadComposeFile(challengePath)
+		if err != nil {
+			v.add(filepath.Join(challengePath, "docker-compose.yml"), "", "%v", err)
+			return
+		}
+		if valid && challenge.DynamicFlag != nil {
+			for i, name := range challenge.DynamicFlag.Services {
+				if _, ok := cf.Services[name]; !ok {
+					v.add(manifestPath, fmt.Sprintf("dynamic_flag.services.%d", i), "service '%s' is not defined in docker-compose.yml", name)
+				}
+			}
+		}
+	}
+}
+
+// validateDynamicFlag checks that a dynamic flag can be generated and does
+// not clash with static flags.
+func (v *validator) validateDynamicFlag(manifestPath string, challenge Challenge) {
+	if !challenge.Flag.IsZero() || len(challenge.Flags) > 0 {
+		v.add(manifestPath, "dynamic_flag", "cannot be combined with flag or flags")
+	}
+	if _, err := challenge.DynamicFlag.Generate(); err != nil {
+		v.add(manifestPath, "dynamic_flag.template", "%v", err)
+	}
+}
+
+// runValidate implements the validate command. It prints every problem and
+// returns the process exit code.
+func runValidate(root string) int {
+	fmt.Printf("Validando desafios em %s...\n", root)
+	problems := validateRoot(root)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("\n%d problema(s) encontrado(s).\n", len(problems))
+		return 1
+	}
+	fmt.Println("Nenhum problema encontrado.")
+	return 0
+}